@@ -179,6 +179,48 @@ func (dao Dao) QueryList(sql string, args ...interface{}) (*ListResult, error) {
 	return &ListResult{columns, list}, nil
 }
 
+// QueryEach streams the rows of a query to f without keeping them in memory.
+// f is called once with a nil record when the columns are known, then once per row,
+// returning an error from f stops the iteration.
+func (dao Dao) QueryEach(sql string, f func(columns []string, record map[string]string) error, args ...interface{}) error {
+	rows, err := dao.db.Query(sql, args...)
+	if err != nil {
+		logger.Error("QueryEach error, sql is {}, err : {}", sql, err)
+		return err
+	}
+	defer rows.Close()
+	columns, _ := rows.Columns()
+	for i := 0; i < len(columns); i++ {
+		columns[i] = strings.ToLower(columns[i])
+	}
+	scanArgs := make([]interface{}, len(columns))
+	values := make([]interface{}, len(columns))
+	for j := range values {
+		scanArgs[j] = &values[j]
+	}
+	err = f(columns, nil)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		record := make(map[string]string)
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return err
+		}
+		for i, col := range values {
+			if col != nil {
+				record[columns[i]] = toStr(col)
+			}
+		}
+		err = f(columns, record)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func (dao Dao) Update(sql string, args ...interface{}) (int64, error) {
 
 	result, err := dao.db.Exec(sql, args...)
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	FormatCSV        = "csv"
+	FormatTSV        = "tsv"
+	FormatJSONLines  = "jsonl"
+	utf8BOM          = "\xEF\xBB\xBF"
+	defaultExportSep = ','
+)
+
+// ExportOptions controls how a RowWriter renders records.
+// Null, QuoteAll, BOM and UseCRLF only apply to csv and tsv, json lines always writes null.
+type ExportOptions struct {
+	Null     string // text written for NULL columns, default is empty, other values are quoted when set
+	QuoteAll bool   // quote every field but NULL, by default only fields that need it are quoted
+	BOM      bool   // write utf-8 BOM first so Excel detects the encoding
+	UseCRLF  bool   // end lines with \r\n instead of \n
+	NoHeader bool   // skip the header row
+}
+
+// RowWriter writes query records to an io.Writer as csv, tsv or json lines,
+// columns are written in the order given to WriteHeader.
+type RowWriter struct {
+	format  string
+	writer  *bufio.Writer
+	opts    ExportOptions
+	columns []string
+	started bool
+}
+
+func NewCSVWriter(w io.Writer, opts *ExportOptions) *RowWriter {
+	return newRowWriter(FormatCSV, w, opts)
+}
+
+func NewTSVWriter(w io.Writer, opts *ExportOptions) *RowWriter {
+	return newRowWriter(FormatTSV, w, opts)
+}
+
+func NewJSONLinesWriter(w io.Writer, opts *ExportOptions) *RowWriter {
+	return newRowWriter(FormatJSONLines, w, opts)
+}
+
+func newRowWriter(format string, w io.Writer, opts *ExportOptions) *RowWriter {
+	rowWriter := &RowWriter{format: format, writer: bufio.NewWriter(w)}
+	if opts != nil {
+		rowWriter.opts = *opts
+	}
+	return rowWriter
+}
+
+// WriteHeader sets the column order and writes the header row for csv and tsv.
+func (rowWriter *RowWriter) WriteHeader(columns []string) error {
+	if rowWriter.started {
+		return errors.New("export header already written")
+	}
+	rowWriter.started = true
+	rowWriter.columns = columns
+	if rowWriter.format == FormatJSONLines {
+		return nil
+	}
+	if rowWriter.opts.BOM {
+		if _, err := rowWriter.writer.WriteString(utf8BOM); err != nil {
+			return err
+		}
+	}
+	if rowWriter.opts.NoHeader {
+		return nil
+	}
+	return rowWriter.writeLine(columns, nil)
+}
+
+// Write writes one record, columns missing from the record are treated as NULL.
+func (rowWriter *RowWriter) Write(record map[string]string) error {
+	if !rowWriter.started {
+		return errors.New("export header must be written before records")
+	}
+	if rowWriter.format == FormatJSONLines {
+		return rowWriter.writeJSONLine(record)
+	}
+	fields := make([]string, len(rowWriter.columns))
+	nulls := make([]bool, len(rowWriter.columns))
+	for i, col := range rowWriter.columns {
+		val, ok := record[col]
+		if ok {
+			fields[i] = val
+		} else {
+			fields[i] = rowWriter.opts.Null
+			nulls[i] = true
+		}
+	}
+	return rowWriter.writeLine(fields, nulls)
+}
+
+// WriteResult writes the header and every record of result, then flushes.
+func (rowWriter *RowWriter) WriteResult(result *ListResult) error {
+	if result == nil {
+		return errors.New("export result is nil")
+	}
+	if err := rowWriter.WriteHeader(result.Columns); err != nil {
+		return err
+	}
+	for _, record := range result.List {
+		if err := rowWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	return rowWriter.Flush()
+}
+
+func (rowWriter *RowWriter) Flush() error {
+	return rowWriter.writer.Flush()
+}
+
+func (rowWriter *RowWriter) separator() byte {
+	if rowWriter.format == FormatTSV {
+		return '\t'
+	}
+	return defaultExportSep
+}
+
+// writeLine writes one csv or tsv line, nulls is nil for the header. The line is written
+// with a single call so an error of the underlying writer is returned for the row.
+func (rowWriter *RowWriter) writeLine(fields []string, nulls []bool) error {
+	var line strings.Builder
+	for i, field := range fields {
+		if i > 0 {
+			line.WriteByte(rowWriter.separator())
+		}
+		// the NULL placeholder is written as is, real values are quoted when they could
+		// be taken for it
+		quote := rowWriter.needQuote(field)
+		if nulls != nil {
+			quote = !nulls[i] && (quote || rowWriter.opts.Null != "")
+		}
+		if quote {
+			line.WriteByte('"')
+			line.WriteString(strings.ReplaceAll(field, `"`, `""`))
+			line.WriteByte('"')
+		} else {
+			line.WriteString(field)
+		}
+	}
+	if rowWriter.opts.UseCRLF {
+		line.WriteString("\r\n")
+	} else {
+		line.WriteByte('\n')
+	}
+	_, err := rowWriter.writer.WriteString(line.String())
+	return err
+}
+
+func (rowWriter *RowWriter) needQuote(field string) bool {
+	if rowWriter.opts.QuoteAll {
+		return true
+	}
+	if field == "" {
+		return false
+	}
+	if strings.IndexByte(field, rowWriter.separator()) >= 0 || strings.ContainsAny(field, "\"\r\n") {
+		return true
+	}
+	return field[0] == ' ' || field[0] == '\t'
+}
+
+func (rowWriter *RowWriter) writeJSONLine(record map[string]string) error {
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, col := range rowWriter.columns {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		key, _ := json.Marshal(col)
+		line.Write(key)
+		line.WriteByte(':')
+		val, ok := record[col]
+		if !ok {
+			line.WriteString("null")
+			continue
+		}
+		b, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		line.Write(b)
+	}
+	line.WriteString("}\n")
+	_, err := rowWriter.writer.Write(line.Bytes())
+	return err
+}
+
+// Export streams the rows of a query into rowWriter without loading them all in memory.
+func Export(querier Querier, rowWriter *RowWriter, sql string, args ...interface{}) error {
+	err := querier.QueryEach(sql, func(columns []string, record map[string]string) error {
+		if record == nil {
+			return rowWriter.WriteHeader(columns)
+		}
+		return rowWriter.Write(record)
+	}, args...)
+	if err != nil {
+		return err
+	}
+	return rowWriter.Flush()
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRowWriter(t *testing.T) {
+	result := &ListResult{
+		Columns: []string{"id", "name", "note"},
+		List: []map[string]string{
+			{"id": "1", "name": "tom", "note": `say "hi", bye`},
+			{"id": "2", "name": " lead", "note": "a\tb"},
+			{"id": "3", "name": "", "note": "line\nbreak"},
+			{"id": "4", "name": "NULL"},
+		},
+	}
+	tests := []struct {
+		name      string
+		newWriter func(w *bytes.Buffer, opts *ExportOptions) *RowWriter
+		opts      *ExportOptions
+		want      string
+	}{
+		{"csv", csvWriter, nil, "id,name,note\n" +
+			"1,tom,\"say \"\"hi\"\", bye\"\n" +
+			"2,\" lead\",a\tb\n" +
+			"3,,\"line\nbreak\"\n" +
+			"4,NULL,\n"},
+		{"tsv", tsvWriter, nil, "id\tname\tnote\n" +
+			"1\ttom\t\"say \"\"hi\"\", bye\"\n" +
+			"2\t\" lead\"\t\"a\tb\"\n" +
+			"3\t\t\"line\nbreak\"\n" +
+			"4\tNULL\t\n"},
+		{"quote all", csvWriter, &ExportOptions{QuoteAll: true}, "\"id\",\"name\",\"note\"\n" +
+			"\"1\",\"tom\",\"say \"\"hi\"\", bye\"\n" +
+			"\"2\",\" lead\",\"a\tb\"\n" +
+			"\"3\",\"\",\"line\nbreak\"\n" +
+			"\"4\",\"NULL\",\n"},
+		{"null placeholder", csvWriter, &ExportOptions{Null: "NULL"}, "id,name,note\n" +
+			"\"1\",\"tom\",\"say \"\"hi\"\", bye\"\n" +
+			"\"2\",\" lead\",\"a\tb\"\n" +
+			"\"3\",\"\",\"line\nbreak\"\n" +
+			"\"4\",\"NULL\",NULL\n"},
+		{"bom crlf no header", csvWriter, &ExportOptions{BOM: true, UseCRLF: true, NoHeader: true}, utf8BOM +
+			"1,tom,\"say \"\"hi\"\", bye\"\r\n" +
+			"2,\" lead\",a\tb\r\n" +
+			"3,,\"line\nbreak\"\r\n" +
+			"4,NULL,\r\n"},
+		{"json lines", jsonLinesWriter, &ExportOptions{Null: "NULL", BOM: true}, "" +
+			`{"id":"1","name":"tom","note":"say \"hi\", bye"}` + "\n" +
+			`{"id":"2","name":" lead","note":"a\tb"}` + "\n" +
+			`{"id":"3","name":"","note":"line\nbreak"}` + "\n" +
+			`{"id":"4","name":"NULL","note":null}` + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := test.newWriter(&buf, test.opts).WriteResult(result); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("output = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func csvWriter(w *bytes.Buffer, opts *ExportOptions) *RowWriter {
+	return NewCSVWriter(w, opts)
+}
+
+func tsvWriter(w *bytes.Buffer, opts *ExportOptions) *RowWriter {
+	return NewTSVWriter(w, opts)
+}
+
+func jsonLinesWriter(w *bytes.Buffer, opts *ExportOptions) *RowWriter {
+	return NewJSONLinesWriter(w, opts)
+}
+
+type failingWriter struct{}
+
+var errWriteFailed = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+func TestRowWriterReturnsWriteError(t *testing.T) {
+	long := strings.Repeat("x", 8192)
+	for _, rowWriter := range []*RowWriter{NewCSVWriter(failingWriter{}, nil), NewJSONLinesWriter(failingWriter{}, nil)} {
+		if err := rowWriter.WriteHeader([]string{"v"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := rowWriter.Write(map[string]string{"v": long}); err != errWriteFailed {
+			t.Errorf("%s Write error = %v, want %v", rowWriter.format, err, errWriteFailed)
+		}
+	}
+}
+
+func TestRowWriterOrder(t *testing.T) {
+	rowWriter := NewCSVWriter(&bytes.Buffer{}, nil)
+	if err := rowWriter.Write(map[string]string{}); err == nil {
+		t.Error("Write before WriteHeader returned no error")
+	}
+	rowWriter.WriteHeader([]string{"a"})
+	if err := rowWriter.WriteHeader([]string{"a"}); err == nil {
+		t.Error("second WriteHeader returned no error")
+	}
+}
+
+func TestExport(t *testing.T) {
+	fake := NewFakeDao()
+	fake.Expect(`select`).WillReturnRows([]string{"ID", "Name"}, []interface{}{1, "tom"}, []interface{}{2, nil})
+	var buf bytes.Buffer
+	if err := Export(fake, NewCSVWriter(&buf, &ExportOptions{Null: `\N`}), "select id, name from user"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "id,name\n\"1\",\"tom\"\n\"2\",\\N\n"; buf.String() != want {
+		t.Errorf("Export = %q, want %q", buf.String(), want)
+	}
+}
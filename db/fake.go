@@ -0,0 +1,327 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/wellmoon/go/zjson"
+)
+
+// Statement is a sql statement executed through a Recorder or FakeDao.
+type Statement struct {
+	Method string
+	SQL    string
+	Args   []interface{}
+}
+
+// Recorder wraps a Querier and captures every statement passed to it.
+type Recorder struct {
+	target     Querier
+	statements []Statement
+	lock       sync.Mutex
+}
+
+func NewRecorder(target Querier) *Recorder {
+	return &Recorder{target: target}
+}
+
+func (recorder *Recorder) record(method string, sql string, args []interface{}) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.statements = append(recorder.statements, Statement{method, sql, args})
+}
+
+// Statements returns a copy of the recorded statements in execution order.
+func (recorder *Recorder) Statements() []Statement {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	res := make([]Statement, len(recorder.statements))
+	copy(res, recorder.statements)
+	return res
+}
+
+func (recorder *Recorder) Reset() {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.statements = nil
+}
+
+func (recorder *Recorder) QueryMap(sql string, args ...interface{}) (map[string]string, error) {
+	recorder.record("QueryMap", sql, args)
+	return recorder.target.QueryMap(sql, args...)
+}
+
+func (recorder *Recorder) QueryMapInterface(sql string, args ...interface{}) (map[string]interface{}, error) {
+	recorder.record("QueryMapInterface", sql, args)
+	return recorder.target.QueryMapInterface(sql, args...)
+}
+
+func (recorder *Recorder) QueryList(sql string, args ...interface{}) (*ListResult, error) {
+	recorder.record("QueryList", sql, args)
+	return recorder.target.QueryList(sql, args...)
+}
+
+func (recorder *Recorder) QueryEach(sql string, f func(columns []string, record map[string]string) error, args ...interface{}) error {
+	recorder.record("QueryEach", sql, args)
+	return recorder.target.QueryEach(sql, f, args...)
+}
+
+func (recorder *Recorder) Update(sql string, args ...interface{}) (int64, error) {
+	recorder.record("Update", sql, args)
+	return recorder.target.Update(sql, args...)
+}
+
+func (recorder *Recorder) Insert(sql string, args ...interface{}) (int64, error) {
+	recorder.record("Insert", sql, args)
+	return recorder.target.Insert(sql, args...)
+}
+
+func (recorder *Recorder) SelectCount(sql string, args ...interface{}) (int, error) {
+	recorder.record("SelectCount", sql, args)
+	return recorder.target.SelectCount(sql, args...)
+}
+
+// Expectation is a scripted answer of FakeDao for statements matching a pattern.
+type Expectation struct {
+	pattern  *regexp.Regexp
+	columns  []string
+	rows     [][]interface{}
+	affected int64
+	lastId   int64
+	count    int
+	err      error
+	times    int
+	hits     int
+}
+
+// WillReturnRows sets the rows returned by QueryMap, QueryMapInterface, QueryList and QueryEach,
+// each row holds one value per column, nil values are skipped like sql NULL.
+func (expectation *Expectation) WillReturnRows(columns []string, rows ...[]interface{}) *Expectation {
+	expectation.columns = columns
+	expectation.rows = rows
+	return expectation
+}
+
+// WillReturnAffected sets the affected rows returned by Update.
+func (expectation *Expectation) WillReturnAffected(affected int64) *Expectation {
+	expectation.affected = affected
+	return expectation
+}
+
+// WillReturnId sets the last insert id returned by Insert.
+func (expectation *Expectation) WillReturnId(id int64) *Expectation {
+	expectation.lastId = id
+	return expectation
+}
+
+// WillReturnCount sets the value returned by SelectCount.
+func (expectation *Expectation) WillReturnCount(count int) *Expectation {
+	expectation.count = count
+	return expectation
+}
+
+func (expectation *Expectation) WillReturnError(err error) *Expectation {
+	expectation.err = err
+	return expectation
+}
+
+// Times limits how many statements the expectation answers, by default it is unlimited.
+func (expectation *Expectation) Times(n int) *Expectation {
+	expectation.times = n
+	return expectation
+}
+
+// FakeDao is an in-memory Querier answering statements with scripted expectations.
+//
+// sample
+// fake := db.NewFakeDao()
+// fake.Expect(`select .* from user where id = \?`).WillReturnRows([]string{"id", "name"}, []interface{}{1, "tom"})
+// fake.Expect(`update user`).WillReturnAffected(1)
+type FakeDao struct {
+	expectations []*Expectation
+	statements   []Statement
+	lock         sync.Mutex
+}
+
+func NewFakeDao() *FakeDao {
+	return &FakeDao{}
+}
+
+var _ Querier = &FakeDao{}
+var _ Querier = &Recorder{}
+
+// Expect registers an expectation for statements matching the regular expression pattern.
+// Expectations are checked in registration order, the first matching one answers.
+func (fake *FakeDao) Expect(pattern string) *Expectation {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	expectation := &Expectation{pattern: regexp.MustCompile(pattern), times: -1}
+	fake.expectations = append(fake.expectations, expectation)
+	return expectation
+}
+
+// Statements returns a copy of the executed statements in execution order.
+func (fake *FakeDao) Statements() []Statement {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	res := make([]Statement, len(fake.statements))
+	copy(res, fake.statements)
+	return res
+}
+
+// ExpectationsWereMet returns an error if any expectation was never used,
+// or was limited by Times and not used that many times.
+func (fake *FakeDao) ExpectationsWereMet() error {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	unmet := make([]string, 0)
+	for _, expectation := range fake.expectations {
+		if expectation.hits == 0 || expectation.times > 0 {
+			unmet = append(unmet, expectation.pattern.String())
+		}
+	}
+	if len(unmet) > 0 {
+		return errors.New("fake dao expectations not met: " + strings.Join(unmet, ", "))
+	}
+	return nil
+}
+
+func (fake *FakeDao) match(method string, sql string, args []interface{}) (*Expectation, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	fake.statements = append(fake.statements, Statement{method, sql, args})
+	for _, expectation := range fake.expectations {
+		if expectation.times == 0 || !expectation.pattern.MatchString(sql) {
+			continue
+		}
+		if expectation.times > 0 {
+			expectation.times--
+		}
+		expectation.hits++
+		return expectation, expectation.err
+	}
+	return nil, fmt.Errorf("fake dao has no expectation for sql: %s", sql)
+}
+
+// fakeStr formats a scripted column like the driver values read by Dao, other values such
+// as bool or time.Time are formatted with zjson.ToStr.
+func fakeStr(value interface{}) string {
+	switch value.(type) {
+	case string, int, int64, float64, []byte:
+		return toStr(value)
+	}
+	return zjson.ToStr(value)
+}
+
+func (expectation *Expectation) lowerColumns() []string {
+	columns := make([]string, len(expectation.columns))
+	for i, col := range expectation.columns {
+		columns[i] = strings.ToLower(col)
+	}
+	return columns
+}
+
+func (fake *FakeDao) QueryMap(sql string, args ...interface{}) (map[string]string, error) {
+	expectation, err := fake.match("QueryMap", sql, args)
+	if err != nil {
+		return nil, err
+	}
+	columns := expectation.lowerColumns()
+	record := make(map[string]string)
+	for _, row := range expectation.rows {
+		for i, col := range row {
+			if col != nil && i < len(columns) {
+				record[columns[i]] = fakeStr(col)
+			}
+		}
+	}
+	return record, nil
+}
+
+func (fake *FakeDao) QueryMapInterface(sql string, args ...interface{}) (map[string]interface{}, error) {
+	expectation, err := fake.match("QueryMapInterface", sql, args)
+	if err != nil {
+		return nil, err
+	}
+	columns := expectation.lowerColumns()
+	record := make(map[string]interface{})
+	for _, row := range expectation.rows {
+		for i, col := range row {
+			if col != nil && i < len(columns) {
+				record[columns[i]] = col
+			}
+		}
+	}
+	return record, nil
+}
+
+func (fake *FakeDao) QueryList(sql string, args ...interface{}) (*ListResult, error) {
+	expectation, err := fake.match("QueryList", sql, args)
+	if err != nil {
+		return nil, err
+	}
+	columns := expectation.lowerColumns()
+	list := make([]map[string]string, 0)
+	for _, row := range expectation.rows {
+		record := make(map[string]string)
+		for i, col := range row {
+			if col != nil && i < len(columns) {
+				record[columns[i]] = fakeStr(col)
+			}
+		}
+		list = append(list, record)
+	}
+	return &ListResult{columns, list}, nil
+}
+
+func (fake *FakeDao) QueryEach(sql string, f func(columns []string, record map[string]string) error, args ...interface{}) error {
+	expectation, err := fake.match("QueryEach", sql, args)
+	if err != nil {
+		return err
+	}
+	columns := expectation.lowerColumns()
+	err = f(columns, nil)
+	if err != nil {
+		return err
+	}
+	for _, row := range expectation.rows {
+		record := make(map[string]string)
+		for i, col := range row {
+			if col != nil && i < len(columns) {
+				record[columns[i]] = fakeStr(col)
+			}
+		}
+		err = f(columns, record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fake *FakeDao) Update(sql string, args ...interface{}) (int64, error) {
+	expectation, err := fake.match("Update", sql, args)
+	if err != nil {
+		return 0, err
+	}
+	return expectation.affected, nil
+}
+
+func (fake *FakeDao) Insert(sql string, args ...interface{}) (int64, error) {
+	expectation, err := fake.match("Insert", sql, args)
+	if err != nil {
+		return 0, err
+	}
+	return expectation.lastId, nil
+}
+
+func (fake *FakeDao) SelectCount(sql string, args ...interface{}) (int, error) {
+	expectation, err := fake.match("SelectCount", sql, args)
+	if err != nil {
+		return 0, err
+	}
+	return expectation.count, nil
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFakeDaoMatchOrder(t *testing.T) {
+	fake := NewFakeDao()
+	fake.Expect(`select .* from user where id`).WillReturnRows([]string{"ID", "Name"}, []interface{}{1, "tom"})
+	fake.Expect(`select .* from user`).WillReturnRows([]string{"id"}, []interface{}{1}, []interface{}{2})
+
+	record, err := fake.QueryMap("select * from user where id = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"id": "1", "name": "tom"}; !reflect.DeepEqual(record, want) {
+		t.Errorf("QueryMap = %v, want %v", record, want)
+	}
+	list, err := fake.QueryList("select id from user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.List) != 2 || list.List[1]["id"] != "2" {
+		t.Errorf("QueryList = %v, want 2 rows from the second expectation", list.List)
+	}
+	if _, err := fake.Update("delete from user"); err == nil {
+		t.Error("Update without a matching expectation returned no error")
+	}
+}
+
+func TestFakeDaoTimes(t *testing.T) {
+	fake := NewFakeDao()
+	fake.Expect(`insert into user`).WillReturnId(1).Times(1)
+	fake.Expect(`insert into user`).WillReturnId(2)
+
+	for _, want := range []int64{1, 2, 2} {
+		id, err := fake.Insert("insert into user (name) values (?)", "tom")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Errorf("Insert = %d, want %d", id, want)
+		}
+	}
+
+	fake = NewFakeDao()
+	fake.Expect(`update user`).WillReturnAffected(3).Times(1)
+	if affected, err := fake.Update("update user set name = ?", "tom"); err != nil || affected != 3 {
+		t.Errorf("Update = %d, %v, want 3, nil", affected, err)
+	}
+	if _, err := fake.Update("update user set name = ?", "tom"); err == nil {
+		t.Error("Update after Times was used up returned no error")
+	}
+}
+
+func TestFakeDaoExpectationsWereMet(t *testing.T) {
+	tests := []struct {
+		name  string
+		calls int
+		times int
+		met   bool
+	}{
+		{"unused", 0, -1, false},
+		{"used", 1, -1, true},
+		{"times used up", 2, 2, true},
+		{"times left", 1, 2, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := NewFakeDao()
+			expectation := fake.Expect(`select count`).WillReturnCount(5)
+			if test.times > 0 {
+				expectation.Times(test.times)
+			}
+			for i := 0; i < test.calls; i++ {
+				if count, err := fake.SelectCount("select count(*) from user"); err != nil || count != 5 {
+					t.Fatalf("SelectCount = %d, %v, want 5, nil", count, err)
+				}
+			}
+			err := fake.ExpectationsWereMet()
+			if test.met && err != nil {
+				t.Errorf("ExpectationsWereMet = %v, want nil", err)
+			}
+			if !test.met && (err == nil || !strings.Contains(err.Error(), "select count")) {
+				t.Errorf("ExpectationsWereMet = %v, want an error naming the pattern", err)
+			}
+		})
+	}
+}
+
+func TestFakeDaoReturnsError(t *testing.T) {
+	errDown := errors.New("db down")
+	fake := NewFakeDao()
+	fake.Expect(`.`).WillReturnError(errDown)
+
+	if _, err := fake.QueryMap("select 1"); err != errDown {
+		t.Errorf("QueryMap error = %v, want %v", err, errDown)
+	}
+	if _, err := fake.QueryMapInterface("select 1"); err != errDown {
+		t.Errorf("QueryMapInterface error = %v, want %v", err, errDown)
+	}
+	if _, err := fake.QueryList("select 1"); err != errDown {
+		t.Errorf("QueryList error = %v, want %v", err, errDown)
+	}
+	called := false
+	err := fake.QueryEach("select 1", func(columns []string, record map[string]string) error {
+		called = true
+		return nil
+	})
+	if err != errDown || called {
+		t.Errorf("QueryEach = %v, called %v, want %v without calling f", err, called, errDown)
+	}
+	if _, err := fake.Update("update t"); err != errDown {
+		t.Errorf("Update error = %v, want %v", err, errDown)
+	}
+	if _, err := fake.Insert("insert t"); err != errDown {
+		t.Errorf("Insert error = %v, want %v", err, errDown)
+	}
+	if _, err := fake.SelectCount("select count(*)"); err != errDown {
+		t.Errorf("SelectCount error = %v, want %v", err, errDown)
+	}
+}
+
+func TestFakeDaoNullColumns(t *testing.T) {
+	fake := NewFakeDao()
+	fake.Expect(`select`).WillReturnRows([]string{"id", "name", "ok"}, []interface{}{1, nil, true})
+	var records []map[string]string
+	err := fake.QueryEach("select id, name, ok from user", func(columns []string, record map[string]string) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]string{nil, {"id": "1", "ok": "true"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("QueryEach records = %v, want %v", records, want)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	fake := NewFakeDao()
+	fake.Expect(`.`)
+	recorder := NewRecorder(fake)
+
+	recorder.Update("update user set name = ? where id = ?", "tom", 1)
+	recorder.SelectCount("select count(*) from user")
+	want := []Statement{
+		{"Update", "update user set name = ? where id = ?", []interface{}{"tom", 1}},
+		{"SelectCount", "select count(*) from user", nil},
+	}
+	if got := recorder.Statements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Recorder.Statements = %v, want %v", got, want)
+	}
+	if got := fake.Statements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FakeDao.Statements = %v, want %v", got, want)
+	}
+	recorder.Reset()
+	if got := recorder.Statements(); len(got) != 0 {
+		t.Errorf("Statements after Reset = %v, want none", got)
+	}
+}
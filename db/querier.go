@@ -0,0 +1,16 @@
+package db
+
+// Querier is the set of query methods provided by Dao. Code that only needs to
+// run statements should depend on Querier so it can be tested with FakeDao.
+type Querier interface {
+	QueryMap(sql string, args ...interface{}) (map[string]string, error)
+	QueryMapInterface(sql string, args ...interface{}) (map[string]interface{}, error)
+	QueryList(sql string, args ...interface{}) (*ListResult, error)
+	QueryEach(sql string, f func(columns []string, record map[string]string) error, args ...interface{}) error
+	Update(sql string, args ...interface{}) (int64, error)
+	Insert(sql string, args ...interface{}) (int64, error)
+	SelectCount(sql string, args ...interface{}) (int, error)
+}
+
+var _ Querier = Dao{}
+var _ Querier = &Dao{}
@@ -0,0 +1,180 @@
+package lists
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wellmoon/go/zjson"
+)
+
+// GetE returns the element at idx, or an error if idx is out of range.
+func (arrayList *ArrayList) GetE(idx int) (interface{}, error) {
+	arrayList.mutex.RLock()
+	defer arrayList.mutex.RUnlock()
+	if idx < 0 || idx >= len(arrayList.innerList) {
+		return nil, fmt.Errorf("index %d out of range, size is %d", idx, len(arrayList.innerList))
+	}
+	return arrayList.innerList[idx], nil
+}
+
+func (arrayList *ArrayList) GetStringE(idx int) (string, error) {
+	value, err := arrayList.GetE(idx)
+	if err != nil {
+		return "", err
+	}
+	return zjson.ToStr(value), nil
+}
+
+func (arrayList *ArrayList) GetInt(idx int) int {
+	res, err := arrayList.GetIntE(idx)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (arrayList *ArrayList) GetIntE(idx int) (int, error) {
+	value, err := arrayList.GetE(idx)
+	if err != nil {
+		return 0, err
+	}
+	return zjson.ToInt(value)
+}
+
+func (arrayList *ArrayList) GetInt64(idx int) int64 {
+	res, err := arrayList.GetInt64E(idx)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (arrayList *ArrayList) GetInt64E(idx int) (int64, error) {
+	value, err := arrayList.GetE(idx)
+	if err != nil {
+		return 0, err
+	}
+	return zjson.ToInt64(value)
+}
+
+func (arrayList *ArrayList) GetFloat(idx int) float64 {
+	res, err := arrayList.GetFloatE(idx)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (arrayList *ArrayList) GetFloatE(idx int) (float64, error) {
+	value, err := arrayList.GetE(idx)
+	if err != nil {
+		return 0, err
+	}
+	return zjson.ToFloat64(value)
+}
+
+// GetBool returns false if the element can't be converted, like JSONObject.GetBool.
+func (arrayList *ArrayList) GetBool(idx int) bool {
+	res, _ := arrayList.GetBoolE(idx)
+	return res
+}
+
+func (arrayList *ArrayList) GetBoolE(idx int) (bool, error) {
+	value, err := arrayList.GetE(idx)
+	if err != nil {
+		return false, err
+	}
+	return zjson.ToBool(value)
+}
+
+func (arrayList *ArrayList) GetJSONObject(idx int) *zjson.JSONObject {
+	res, err := arrayList.GetJSONObjectE(idx)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// GetJSONObjectE returns the element at idx as a JSONObject. A map element is wrapped in a
+// new JSONObject sharing the map, use PromoteJSONObject to change it concurrently.
+func (arrayList *ArrayList) GetJSONObjectE(idx int) (*zjson.JSONObject, error) {
+	value, err := arrayList.GetE(idx)
+	if err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case *zjson.JSONObject:
+		return v, nil
+	case map[string]interface{}:
+		obj := zjson.NewObject()
+		obj.ItemMap = v
+		return obj, nil
+	}
+	return zjson.ParseJSONObject(value)
+}
+
+// PromoteJSONObject replaces the map element at idx by a *zjson.JSONObject wrapping it and
+// returns the object, so every caller getting it shares its lock. After that Get(idx)
+// returns the *zjson.JSONObject instead of the map.
+func (arrayList *ArrayList) PromoteJSONObject(idx int) (*zjson.JSONObject, error) {
+	arrayList.mutex.Lock()
+	defer arrayList.mutex.Unlock()
+	if idx < 0 || idx >= len(arrayList.innerList) {
+		return nil, fmt.Errorf("index %d out of range, size is %d", idx, len(arrayList.innerList))
+	}
+	switch v := arrayList.innerList[idx].(type) {
+	case *zjson.JSONObject:
+		return v, nil
+	case map[string]interface{}:
+		obj := zjson.NewObject()
+		obj.ItemMap = v
+		arrayList.innerList[idx] = obj
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("element %d is %T, not an object", idx, v)
+	}
+}
+
+func (arrayList *ArrayList) GetArrayList(idx int) *ArrayList {
+	res, err := arrayList.GetArrayListE(idx)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// GetArrayListE returns the element at idx as an ArrayList, the element may be an ArrayList,
+// a slice or a json array string. A slice of interface{} is shared, not copied.
+func (arrayList *ArrayList) GetArrayListE(idx int) (*ArrayList, error) {
+	value, err := arrayList.GetE(idx)
+	if err != nil {
+		return nil, err
+	}
+	switch value := value.(type) {
+	case *ArrayList:
+		return value, nil
+	case []interface{}:
+		return newArrayListOf(value), nil
+	case nil:
+		return nil, errors.New("element is nil")
+	}
+	var b []byte
+	switch value := value.(type) {
+	case string:
+		b = []byte(value)
+	case []byte:
+		b = value
+	default:
+		b, err = json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := NewArrayList()
+	err = json.Unmarshal(b, &result.innerList)
+	if err != nil {
+		return nil, fmt.Errorf("can't convert element %d to ArrayList: %v", idx, err)
+	}
+	return result, nil
+}
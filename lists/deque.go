@@ -0,0 +1,157 @@
+package lists
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Deque is a double ended queue backed by a ring buffer, it is safe for concurrent use.
+type Deque[T any] struct {
+	buf   []T
+	head  int
+	count int
+	lock  sync.RWMutex
+}
+
+func NewDeque[T any](vals ...T) *Deque[T] {
+	deque := &Deque[T]{}
+	for _, val := range vals {
+		deque.PushBack(val)
+	}
+	return deque
+}
+
+func (deque *Deque[T]) grow() {
+	if deque.count < len(deque.buf) {
+		return
+	}
+	newCap := len(deque.buf) * 2
+	if newCap == 0 {
+		newCap = 8
+	}
+	buf := make([]T, newCap)
+	for i := 0; i < deque.count; i++ {
+		buf[i] = deque.buf[(deque.head+i)%len(deque.buf)]
+	}
+	deque.buf = buf
+	deque.head = 0
+}
+
+func (deque *Deque[T]) PushBack(val T) {
+	deque.lock.Lock()
+	defer deque.lock.Unlock()
+	deque.grow()
+	deque.buf[(deque.head+deque.count)%len(deque.buf)] = val
+	deque.count++
+}
+
+func (deque *Deque[T]) PushFront(val T) {
+	deque.lock.Lock()
+	defer deque.lock.Unlock()
+	deque.grow()
+	deque.head = (deque.head - 1 + len(deque.buf)) % len(deque.buf)
+	deque.buf[deque.head] = val
+	deque.count++
+}
+
+// PopFront removes and returns the first element, ok is false if the deque is empty.
+func (deque *Deque[T]) PopFront() (val T, ok bool) {
+	deque.lock.Lock()
+	defer deque.lock.Unlock()
+	if deque.count == 0 {
+		return val, false
+	}
+	var zero T
+	val = deque.buf[deque.head]
+	deque.buf[deque.head] = zero
+	deque.head = (deque.head + 1) % len(deque.buf)
+	deque.count--
+	return val, true
+}
+
+// PopBack removes and returns the last element, ok is false if the deque is empty.
+func (deque *Deque[T]) PopBack() (val T, ok bool) {
+	deque.lock.Lock()
+	defer deque.lock.Unlock()
+	if deque.count == 0 {
+		return val, false
+	}
+	var zero T
+	idx := (deque.head + deque.count - 1) % len(deque.buf)
+	val = deque.buf[idx]
+	deque.buf[idx] = zero
+	deque.count--
+	return val, true
+}
+
+func (deque *Deque[T]) PeekFront() (val T, ok bool) {
+	deque.lock.RLock()
+	defer deque.lock.RUnlock()
+	if deque.count == 0 {
+		return val, false
+	}
+	return deque.buf[deque.head], true
+}
+
+func (deque *Deque[T]) PeekBack() (val T, ok bool) {
+	deque.lock.RLock()
+	defer deque.lock.RUnlock()
+	if deque.count == 0 {
+		return val, false
+	}
+	return deque.buf[(deque.head+deque.count-1)%len(deque.buf)], true
+}
+
+func (deque *Deque[T]) Size() int {
+	deque.lock.RLock()
+	defer deque.lock.RUnlock()
+	return deque.count
+}
+
+func (deque *Deque[T]) Clear() {
+	deque.lock.Lock()
+	defer deque.lock.Unlock()
+	deque.buf = nil
+	deque.head = 0
+	deque.count = 0
+}
+
+// Values returns a copy of the elements from front to back.
+func (deque *Deque[T]) Values() []T {
+	deque.lock.RLock()
+	defer deque.lock.RUnlock()
+	res := make([]T, deque.count)
+	for i := 0; i < deque.count; i++ {
+		res[i] = deque.buf[(deque.head+i)%len(deque.buf)]
+	}
+	return res
+}
+
+func (deque *Deque[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(deque.Values())
+}
+
+func (deque *Deque[T]) UnmarshalJSON(b []byte) error {
+	vals := make([]T, 0)
+	err := json.Unmarshal(b, &vals)
+	if err != nil {
+		return err
+	}
+	deque.Clear()
+	for _, val := range vals {
+		deque.PushBack(val)
+	}
+	return nil
+}
+
+func (deque *Deque[T]) ToArrayList() *ArrayList {
+	return sliceToArrayList(deque.Values())
+}
+
+func DequeFromArrayList[T any](arrayList *ArrayList) (*Deque[T], error) {
+	vals, err := arrayListToSlice[T](arrayList)
+	if err != nil {
+		return nil, err
+	}
+	return NewDeque(vals...), nil
+}
@@ -0,0 +1,31 @@
+package lists
+
+import "github.com/wellmoon/go/zjson"
+
+// ParseYAML parses a yaml sequence into an ArrayList, see zjson.ParseYAML for the types of
+// the elements. TOML documents are always tables, so lists have no TOML form, put them
+// into a JSONObject instead.
+func ParseYAML(data []byte) (*ArrayList, error) {
+	arr, err := zjson.ParseYAMLArray(data)
+	if err != nil {
+		return nil, err
+	}
+	return &ArrayList{innerList: arr}, nil
+}
+
+func (arrayList *ArrayList) ToYAML() ([]byte, error) {
+	return zjson.ToYAML(arrayList)
+}
+
+// ParseMsgPack parses a MessagePack array into an ArrayList.
+func ParseMsgPack(data []byte) (*ArrayList, error) {
+	arr, err := zjson.ParseMsgPackArray(data)
+	if err != nil {
+		return nil, err
+	}
+	return &ArrayList{innerList: arr}, nil
+}
+
+func (arrayList *ArrayList) ToMsgPack() ([]byte, error) {
+	return zjson.ToMsgPack(arrayList)
+}
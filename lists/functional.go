@@ -0,0 +1,179 @@
+package lists
+
+func newArrayListOf(vals []interface{}) *ArrayList {
+	result := &ArrayList{}
+	result.innerList = vals
+	return result
+}
+
+// Filter returns a new list with the elements satisfying f.
+func (arrayList *ArrayList) Filter(f func(val interface{}) bool) *ArrayList {
+	return newArrayListOf(Filter(arrayList.snapshot(), f))
+}
+
+// Map returns a new list with the result of f for each element.
+func (arrayList *ArrayList) Map(f func(val interface{}) interface{}) *ArrayList {
+	return newArrayListOf(Map(arrayList.snapshot(), f))
+}
+
+// sample
+//
+//	total := list.Reduce(0, func(acc interface{}, val interface{}) interface{} {
+//	    return acc.(int) + val.(*zjson.JSONObject).GetInt("count")
+//	})
+func (arrayList *ArrayList) Reduce(init interface{}, f func(acc interface{}, val interface{}) interface{}) interface{} {
+	return Reduce(arrayList.snapshot(), init, f)
+}
+
+// Find returns the first element satisfying f.
+func (arrayList *ArrayList) Find(f func(val interface{}) bool) (interface{}, bool) {
+	return Find(arrayList.snapshot(), f)
+}
+
+func (arrayList *ArrayList) Any(f func(val interface{}) bool) bool {
+	return Any(arrayList.snapshot(), f)
+}
+
+func (arrayList *ArrayList) All(f func(val interface{}) bool) bool {
+	return All(arrayList.snapshot(), f)
+}
+
+// GroupBy groups the elements by the key returned from f, keeping their order in each group.
+func (arrayList *ArrayList) GroupBy(f func(val interface{}) string) map[string]*ArrayList {
+	groups := GroupBy(arrayList.snapshot(), f)
+	res := make(map[string]*ArrayList, len(groups))
+	for key, vals := range groups {
+		res[key] = newArrayListOf(vals)
+	}
+	return res
+}
+
+// Distinct returns a new list keeping the first element for each key returned from f.
+func (arrayList *ArrayList) Distinct(f func(val interface{}) string) *ArrayList {
+	return newArrayListOf(Distinct(arrayList.snapshot(), f))
+}
+
+// Chunk splits the list into lists of at most size elements.
+func (arrayList *ArrayList) Chunk(size int) []*ArrayList {
+	chunks := Chunk(arrayList.snapshot(), size)
+	res := make([]*ArrayList, len(chunks))
+	for i, chunk := range chunks {
+		res[i] = newArrayListOf(chunk)
+	}
+	return res
+}
+
+// Partition splits the list into elements satisfying f and the others.
+func (arrayList *ArrayList) Partition(f func(val interface{}) bool) (*ArrayList, *ArrayList) {
+	matched, others := Partition(arrayList.snapshot(), f)
+	return newArrayListOf(matched), newArrayListOf(others)
+}
+
+func Filter[T any](vals []T, f func(val T) bool) []T {
+	res := make([]T, 0)
+	for _, val := range vals {
+		if f(val) {
+			res = append(res, val)
+		}
+	}
+	return res
+}
+
+func Map[T any, R any](vals []T, f func(val T) R) []R {
+	res := make([]R, len(vals))
+	for i, val := range vals {
+		res[i] = f(val)
+	}
+	return res
+}
+
+func Reduce[T any, R any](vals []T, init R, f func(acc R, val T) R) R {
+	acc := init
+	for _, val := range vals {
+		acc = f(acc, val)
+	}
+	return acc
+}
+
+func Find[T any](vals []T, f func(val T) bool) (T, bool) {
+	for _, val := range vals {
+		if f(val) {
+			return val, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func Any[T any](vals []T, f func(val T) bool) bool {
+	for _, val := range vals {
+		if f(val) {
+			return true
+		}
+	}
+	return false
+}
+
+// All returns true if every element satisfies f, including for an empty slice.
+func All[T any](vals []T, f func(val T) bool) bool {
+	for _, val := range vals {
+		if !f(val) {
+			return false
+		}
+	}
+	return true
+}
+
+func GroupBy[T any, K comparable](vals []T, f func(val T) K) map[K][]T {
+	res := make(map[K][]T)
+	for _, val := range vals {
+		key := f(val)
+		res[key] = append(res[key], val)
+	}
+	return res
+}
+
+func Distinct[T any, K comparable](vals []T, f func(val T) K) []T {
+	seen := make(map[K]struct{})
+	res := make([]T, 0)
+	for _, val := range vals {
+		key := f(val)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		res = append(res, val)
+	}
+	return res
+}
+
+// Chunk splits vals into slices of at most size elements, size less than 1 is treated as 1.
+func Chunk[T any](vals []T, size int) [][]T {
+	if size < 1 {
+		size = 1
+	}
+	res := make([][]T, 0, (len(vals)+size-1)/size)
+	for from := 0; from < len(vals); from += size {
+		to := from + size
+		if to > len(vals) {
+			to = len(vals)
+		}
+		chunk := make([]T, to-from)
+		copy(chunk, vals[from:to])
+		res = append(res, chunk)
+	}
+	return res
+}
+
+func Partition[T any](vals []T, f func(val T) bool) ([]T, []T) {
+	matched := make([]T, 0)
+	others := make([]T, 0)
+	for _, val := range vals {
+		if f(val) {
+			matched = append(matched, val)
+		} else {
+			others = append(others, val)
+		}
+	}
+	return matched, others
+}
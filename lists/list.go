@@ -0,0 +1,157 @@
+package lists
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// List is a type safe counterpart of ArrayList, it is safe for concurrent use.
+type List[T any] struct {
+	innerList []T
+	lock      sync.RWMutex
+}
+
+func NewList[T any](vals ...T) *List[T] {
+	list := &List[T]{}
+	list.innerList = append(list.innerList, vals...)
+	return list
+}
+
+func (list *List[T]) Add(vals ...T) {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	list.innerList = append(list.innerList, vals...)
+}
+
+func (list *List[T]) AddAll(other *List[T]) {
+	vals := other.Values()
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	list.innerList = append(list.innerList, vals...)
+}
+
+func (list *List[T]) Get(idx int) T {
+	list.lock.RLock()
+	defer list.lock.RUnlock()
+	return list.innerList[idx]
+}
+
+func (list *List[T]) Set(idx int, val T) {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	list.innerList[idx] = val
+}
+
+func (list *List[T]) RemoveAt(idx int) T {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	e := list.innerList[idx]
+	list.innerList = append(list.innerList[:idx], list.innerList[idx+1:]...)
+	return e
+}
+
+// IndexFunc returns the index of the first element satisfying f, or -1.
+func (list *List[T]) IndexFunc(f func(val T) bool) int {
+	list.lock.RLock()
+	defer list.lock.RUnlock()
+	for idx, val := range list.innerList {
+		if f(val) {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (list *List[T]) Size() int {
+	list.lock.RLock()
+	defer list.lock.RUnlock()
+	return len(list.innerList)
+}
+
+func (list *List[T]) Clear() {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	list.innerList = nil
+}
+
+// Values returns a copy of the elements.
+func (list *List[T]) Values() []T {
+	list.lock.RLock()
+	defer list.lock.RUnlock()
+	res := make([]T, len(list.innerList))
+	copy(res, list.innerList)
+	return res
+}
+
+// Each iterates over a snapshot of the list, so f may modify the list.
+func (list *List[T]) Each(f func(idx int, val T)) {
+	for idx, val := range list.Values() {
+		f(idx, val)
+	}
+}
+
+func (list *List[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(list.Values())
+}
+
+func (list *List[T]) UnmarshalJSON(b []byte) error {
+	vals := make([]T, 0)
+	err := json.Unmarshal(b, &vals)
+	if err != nil {
+		return err
+	}
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	list.innerList = vals
+	return nil
+}
+
+func (list *List[T]) ToArrayList() *ArrayList {
+	return sliceToArrayList(list.Values())
+}
+
+// ListFromArrayList converts an ArrayList to a List, elements which are not of type T
+// are converted through json, so numbers decoded as float64 can become an int List.
+func ListFromArrayList[T any](arrayList *ArrayList) (*List[T], error) {
+	vals, err := arrayListToSlice[T](arrayList)
+	if err != nil {
+		return nil, err
+	}
+	return &List[T]{innerList: vals}, nil
+}
+
+func sliceToArrayList[T any](vals []T) *ArrayList {
+	arrayList := NewArrayList()
+	arrayList.innerList = make([]interface{}, len(vals))
+	for i, val := range vals {
+		arrayList.innerList[i] = val
+	}
+	return arrayList
+}
+
+func arrayListToSlice[T any](arrayList *ArrayList) ([]T, error) {
+	arrayList.mutex.RLock()
+	defer arrayList.mutex.RUnlock()
+	vals := make([]T, len(arrayList.innerList))
+	for i, e := range arrayList.innerList {
+		val, err := convertValue[T](e)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
+func convertValue[T any](e interface{}) (T, error) {
+	val, ok := e.(T)
+	if ok {
+		return val, nil
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return val, err
+	}
+	err = json.Unmarshal(b, &val)
+	return val, err
+}
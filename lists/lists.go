@@ -10,9 +10,13 @@ import (
 	"github.com/wellmoon/go/zjson"
 )
 
+// ArrayList is safe for concurrent use. Reads hold a read lock and writes a write lock,
+// compound operations like Remove, RemoveIf and ComputeIfAbsent are atomic, and
+// Each, GetArray and the functional methods work on a snapshot taken under the lock.
+// The elements themselves are not protected.
 type ArrayList struct {
 	innerList []interface{}
-	mutex     sync.Mutex
+	mutex     sync.RWMutex
 }
 type Serializable interface {
 	ToString()
@@ -22,6 +26,14 @@ func NewArrayList() *ArrayList {
 	return &ArrayList{}
 }
 
+func (arrayList *ArrayList) snapshot() []interface{} {
+	arrayList.mutex.RLock()
+	defer arrayList.mutex.RUnlock()
+	res := make([]interface{}, len(arrayList.innerList))
+	copy(res, arrayList.innerList)
+	return res
+}
+
 func (arrayList *ArrayList) Add(val interface{}) {
 	arrayList.mutex.Lock()
 	arrayList.innerList = append(arrayList.innerList, val)
@@ -29,13 +41,59 @@ func (arrayList *ArrayList) Add(val interface{}) {
 }
 
 func (arrayList *ArrayList) AddAll(val *ArrayList) {
+	// take the snapshot before locking, val may be arrayList itself
+	vals := val.snapshot()
 	arrayList.mutex.Lock()
 	defer arrayList.mutex.Unlock()
-	arrayList.innerList = append(arrayList.innerList, val.innerList...)
+	arrayList.innerList = append(arrayList.innerList, vals...)
 }
 
 func (arrayList *ArrayList) Remove(val interface{}) interface{} {
-	return arrayList.RemoveAt(arrayList.IndexOf(val))
+	arrayList.mutex.Lock()
+	defer arrayList.mutex.Unlock()
+	return arrayList.removeAt(arrayList.indexOf(val))
+}
+
+// RemoveIf removes every element satisfying f and returns the number of removed elements.
+func (arrayList *ArrayList) RemoveIf(f func(val interface{}) bool) int {
+	arrayList.mutex.Lock()
+	defer arrayList.mutex.Unlock()
+	kept := make([]interface{}, 0, len(arrayList.innerList))
+	for _, val := range arrayList.innerList {
+		if !f(val) {
+			kept = append(kept, val)
+		}
+	}
+	removed := len(arrayList.innerList) - len(kept)
+	arrayList.innerList = kept
+	return removed
+}
+
+// AddIfAbsent adds val unless an equal element exists, returns true if val was added.
+func (arrayList *ArrayList) AddIfAbsent(val interface{}) bool {
+	arrayList.mutex.Lock()
+	defer arrayList.mutex.Unlock()
+	if arrayList.indexOf(val) != -1 {
+		return false
+	}
+	arrayList.innerList = append(arrayList.innerList, val)
+	return true
+}
+
+// ComputeIfAbsent returns the first element satisfying match, if there is none the result
+// of create is added and returned. match and create are called with the list locked,
+// so they must not call methods of the list.
+func (arrayList *ArrayList) ComputeIfAbsent(match func(val interface{}) bool, create func() interface{}) interface{} {
+	arrayList.mutex.Lock()
+	defer arrayList.mutex.Unlock()
+	for _, val := range arrayList.innerList {
+		if match(val) {
+			return val
+		}
+	}
+	val := create()
+	arrayList.innerList = append(arrayList.innerList, val)
+	return val
 }
 
 func (arrayList *ArrayList) Contains(val interface{}) bool {
@@ -43,8 +101,12 @@ func (arrayList *ArrayList) Contains(val interface{}) bool {
 }
 
 func (arrayList *ArrayList) IndexOf(val interface{}) int {
-	arrayList.mutex.Lock()
-	defer arrayList.mutex.Unlock()
+	arrayList.mutex.RLock()
+	defer arrayList.mutex.RUnlock()
+	return arrayList.indexOf(val)
+}
+
+func (arrayList *ArrayList) indexOf(val interface{}) int {
 	for idx, e := range arrayList.innerList {
 		if e == val {
 			return idx
@@ -54,51 +116,67 @@ func (arrayList *ArrayList) IndexOf(val interface{}) int {
 }
 
 func (arrayList *ArrayList) Size() int {
+	arrayList.mutex.RLock()
+	defer arrayList.mutex.RUnlock()
 	return len(arrayList.innerList)
 }
 
 func (arrayList *ArrayList) RemoveAt(idx int) interface{} {
+	arrayList.mutex.Lock()
+	defer arrayList.mutex.Unlock()
+	return arrayList.removeAt(idx)
+}
+
+func (arrayList *ArrayList) removeAt(idx int) interface{} {
 	if idx < 0 {
 		return nil
 	}
-	arrayList.mutex.Lock()
-	defer arrayList.mutex.Unlock()
 	e := arrayList.innerList[idx]
 	arrayList.innerList = append(arrayList.innerList[:idx], arrayList.innerList[idx+1:]...)
 	return e
 }
 
 func (arrayList *ArrayList) Get(idx int) interface{} {
+	arrayList.mutex.RLock()
+	defer arrayList.mutex.RUnlock()
 	return arrayList.innerList[idx]
 }
 
 func (arrayList *ArrayList) GetString(idx int) string {
-	return zjson.ToStr(arrayList.innerList[idx])
+	return zjson.ToStr(arrayList.Get(idx))
 }
 
+// GetArray returns a snapshot of the elements, changing it does not change the list.
 func (arrayList *ArrayList) GetArray() []interface{} {
-	return arrayList.innerList
+	return arrayList.snapshot()
 }
 
+// GetSubArray returns a snapshot of the elements in [from, to).
 func (arrayList *ArrayList) GetSubArray(from int, to int) []interface{} {
-	if to > arrayList.Size() {
-		to = arrayList.Size()
+	arrayList.mutex.RLock()
+	defer arrayList.mutex.RUnlock()
+	if to > len(arrayList.innerList) {
+		to = len(arrayList.innerList)
 	}
-	return arrayList.innerList[from:to]
+	res := make([]interface{}, to-from)
+	copy(res, arrayList.innerList[from:to])
+	return res
 }
 
 func (arrayList *ArrayList) MarshalJSON() ([]byte, error) {
+	arrayList.mutex.RLock()
+	defer arrayList.mutex.RUnlock()
 	res, err := json.Marshal(arrayList.innerList)
 	return res, err
 }
 
 func (arrayList *ArrayList) ToString() string {
-	res, _ := json.Marshal(arrayList.innerList)
+	res, _ := arrayList.MarshalJSON()
 	return string(res)
 }
 
 func (arrayList *ArrayList) ToBytes() []byte {
-	res, _ := json.Marshal(arrayList.innerList)
+	res, _ := arrayList.MarshalJSON()
 	return res
 }
 
@@ -133,49 +211,47 @@ func ToArrayList(al interface{}) *ArrayList {
 	}
 }
 
-// sort by ToString(), if ToString method not exist, sort by hashCode
+// sort by ToString(), if ToString method not exist, sort by json string.
+// Returns a new sorted list, elements with the same key keep their order.
 func (arrayList *ArrayList) Sort() *ArrayList {
-	tempMap := make(map[string]interface{})
-	keys := make([]string, 0)
-	for _, val := range arrayList.innerList {
-		kind := reflect.TypeOf(val).Kind()
-		if kind == reflect.Ptr {
-			in := make([]reflect.Value, 0)
-			v := reflect.ValueOf(val)
-			rv := v.MethodByName("ToString")
-			if !rv.IsValid() {
-				key := zjson.ToStr(val)
-				tempMap[key] = val
-				keys = append(keys, key)
-			} else {
-				ret := rv.Call(in)
-				key := ret[0].Interface().(string)
-				tempMap[key] = val
-				keys = append(keys, key)
-			}
-
-		} else {
-			key := zjson.ToStr(val)
-			tempMap[key] = val
-			keys = append(keys, key)
-		}
+	vals := arrayList.snapshot()
+	keys := make([]string, len(vals))
+	for i, val := range vals {
+		keys[i] = sortKey(val)
 	}
-	resList := make([]interface{}, 0)
-	sort.Strings(sort.StringSlice(keys))
-	for _, key := range keys {
-		resList = append(resList, tempMap[key])
+	idxs := make([]int, len(vals))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.SliceStable(idxs, func(i, j int) bool {
+		return keys[idxs[i]] < keys[idxs[j]]
+	})
+	resList := make([]interface{}, len(vals))
+	for i, idx := range idxs {
+		resList[i] = vals[idx]
 	}
 	result := &ArrayList{}
 	result.innerList = resList
 	return result
 }
 
+func sortKey(val interface{}) string {
+	if val != nil && reflect.TypeOf(val).Kind() == reflect.Ptr {
+		rv := reflect.ValueOf(val).MethodByName("ToString")
+		if rv.IsValid() && rv.Type().NumIn() == 0 && rv.Type().NumOut() == 1 && rv.Type().Out(0).Kind() == reflect.String {
+			return rv.Call(nil)[0].String()
+		}
+	}
+	return zjson.ToStr(val)
+}
+
+// Each iterates over a snapshot of the list, so f may modify the list.
 // sample
 // delList.Each(func(idx int, v interface{}) {
 //     do something
 // })
 func (arrayList *ArrayList) Each(f func(key int, val interface{})) {
-	for idx, val := range arrayList.innerList {
+	for idx, val := range arrayList.snapshot() {
 		f(idx, val)
 	}
 }
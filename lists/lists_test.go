@@ -0,0 +1,425 @@
+package lists
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestArrayListConcurrent is meant to run with go test -race.
+func TestArrayListConcurrent(t *testing.T) {
+	list := NewArrayList()
+	for i := 0; i < 100; i++ {
+		list.Add(i)
+	}
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				f(i)
+			}
+		}()
+	}
+	run(func(i int) {
+		list.Add(1000 + i)
+	})
+	run(func(i int) {
+		list.Remove(1000 + i)
+	})
+	run(func(i int) {
+		list.RemoveIf(func(val interface{}) bool {
+			return val == 2000+i
+		})
+	})
+	run(func(i int) {
+		list.ComputeIfAbsent(func(val interface{}) bool {
+			return val == 2000+i
+		}, func() interface{} {
+			return 2000 + i
+		})
+	})
+	run(func(i int) {
+		list.Each(func(idx int, val interface{}) {
+			if val == nil {
+				t.Errorf("nil element at %d", idx)
+			}
+		})
+	})
+	run(func(i int) {
+		// the first 100 elements are never removed
+		if val := list.Get(i % 100); val != i%100 {
+			t.Errorf("Get(%d) = %v", i%100, val)
+		}
+	})
+	run(func(i int) {
+		data, err := list.MarshalJSON()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var vals []interface{}
+		if err := json.Unmarshal(data, &vals); err != nil {
+			t.Error(err)
+		}
+	})
+	wg.Wait()
+
+	seen := make(map[interface{}]int)
+	list.Each(func(idx int, val interface{}) {
+		seen[val]++
+	})
+	for val, num := range seen {
+		if num > 1 {
+			t.Errorf("%v added %d times", val, num)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if seen[i] != 1 {
+			t.Errorf("%d lost", i)
+		}
+	}
+}
+
+func TestCompareValuesIsTransitive(t *testing.T) {
+	vals := []interface{}{nil, false, true, -1, 0, 2, 10, 1.5, "1.10", "1.5", "1.5a", "10", "item2",
+		"item10", "a01", "a1", "NaN", "", json.Number("3"), json.Number("2.5")}
+	for _, a := range vals {
+		if CompareValues(a, a) != 0 {
+			t.Errorf("CompareValues(%#v, %#v) != 0", a, a)
+		}
+		for _, b := range vals {
+			ab := CompareValues(a, b)
+			if ba := CompareValues(b, a); ab != -ba {
+				t.Errorf("CompareValues(%#v, %#v) = %d but reversed = %d", a, b, ab, ba)
+			}
+			for _, c := range vals {
+				if ab < 0 && CompareValues(b, c) < 0 && CompareValues(a, c) >= 0 {
+					t.Errorf("%#v < %#v < %#v but not %#v < %#v", a, b, c, a, c)
+				}
+			}
+		}
+	}
+}
+
+func TestSortByValueAndBinarySearch(t *testing.T) {
+	list := NewArrayList()
+	for _, val := range []interface{}{"1.5a", "1.10", true, "item10", 3, nil, "1.5", "item2"} {
+		list.Add(val)
+	}
+	list.SortByValue()
+	want := []interface{}{nil, true, "1.10", "1.5", 3, "1.5a", "item2", "item10"}
+	for i, val := range list.GetArray() {
+		if val != want[i] {
+			t.Fatalf("sorted list is %v, want %v", list.GetArray(), want)
+		}
+	}
+	for i, val := range want {
+		if idx, ok := list.BinarySearch(val); !ok || idx != i {
+			t.Errorf("BinarySearch(%#v) = %d, %v, want %d, true", val, idx, ok, i)
+		}
+	}
+}
+
+func TestZeroPriorityQueue(t *testing.T) {
+	var pq PriorityQueue[int]
+	if pq.Size() != 0 {
+		t.Errorf("Size of a zero queue is %d", pq.Size())
+	}
+	if _, ok := pq.Pop(); ok {
+		t.Error("Pop of a zero queue returned an element")
+	}
+	if _, ok := pq.Peek(); ok {
+		t.Error("Peek of a zero queue returned an element")
+	}
+	if data, err := pq.MarshalJSON(); err != nil || string(data) != "[]" {
+		t.Errorf("MarshalJSON of a zero queue returned %s, %v", data, err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Push on a zero queue didn't panic")
+		}
+	}()
+	pq.Push(1)
+}
+
+func TestArrayListFormats(t *testing.T) {
+	list := NewArrayList()
+	list.Add(int64(1))
+	list.Add(1.5)
+	list.Add("x")
+	list.Add(nil)
+	list.Add(true)
+	tests := []struct {
+		name   string
+		encode func() ([]byte, error)
+		decode func(data []byte) (*ArrayList, error)
+	}{
+		{"yaml", list.ToYAML, ParseYAML},
+		{"msgpack", list.ToMsgPack, ParseMsgPack},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := test.encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := test.decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.GetArray(), list.GetArray()) {
+				t.Errorf("round trip = %#v, want %#v", got.GetArray(), list.GetArray())
+			}
+		})
+	}
+	if _, err := ParseYAML([]byte("a: 1")); err == nil {
+		t.Error("ParseYAML of a mapping returned no error")
+	}
+}
+
+func accessorsList() *ArrayList {
+	list := NewArrayList()
+	list.Add(3)
+	list.Add("x")
+	list.Add(map[string]interface{}{"a": 1})
+	list.Add([]interface{}{1, 2})
+	list.Add(nil)
+	list.Add(true)
+	list.Add(2.5)
+	return list
+}
+
+func TestAccessorsOutOfRange(t *testing.T) {
+	list := accessorsList()
+	getters := map[string]func(idx int) error{
+		"GetE":              func(idx int) error { _, err := list.GetE(idx); return err },
+		"GetStringE":        func(idx int) error { _, err := list.GetStringE(idx); return err },
+		"GetIntE":           func(idx int) error { _, err := list.GetIntE(idx); return err },
+		"GetInt64E":         func(idx int) error { _, err := list.GetInt64E(idx); return err },
+		"GetFloatE":         func(idx int) error { _, err := list.GetFloatE(idx); return err },
+		"GetBoolE":          func(idx int) error { _, err := list.GetBoolE(idx); return err },
+		"GetJSONObjectE":    func(idx int) error { _, err := list.GetJSONObjectE(idx); return err },
+		"GetArrayListE":     func(idx int) error { _, err := list.GetArrayListE(idx); return err },
+		"PromoteJSONObject": func(idx int) error { _, err := list.PromoteJSONObject(idx); return err },
+	}
+	for name, get := range getters {
+		for _, idx := range []int{-1, list.Size()} {
+			if err := get(idx); err == nil {
+				t.Errorf("%s(%d) returned no error", name, idx)
+			}
+		}
+	}
+}
+
+// mustPanic returns true if f panics.
+func mustPanic(f func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	f()
+	return false
+}
+
+func TestAccessorsWrongType(t *testing.T) {
+	list := accessorsList()
+	tests := []struct {
+		name       string
+		get        func() error
+		getOrPanic func()
+	}{
+		{"GetInt of a string", func() error { _, err := list.GetIntE(1); return err }, func() { list.GetInt(1) }},
+		{"GetInt of a float", func() error { _, err := list.GetIntE(6); return err }, func() { list.GetInt(6) }},
+		{"GetInt64 of nil", func() error { _, err := list.GetInt64E(4); return err }, func() { list.GetInt64(4) }},
+		{"GetFloat of a string", func() error { _, err := list.GetFloatE(1); return err }, func() { list.GetFloat(1) }},
+		{"GetBool of a string", func() error { _, err := list.GetBoolE(1); return err }, nil},
+		{"GetJSONObject of an int", func() error { _, err := list.GetJSONObjectE(0); return err }, func() { list.GetJSONObject(0) }},
+		{"GetJSONObject of an array", func() error { _, err := list.GetJSONObjectE(3); return err }, func() { list.GetJSONObject(3) }},
+		{"GetArrayList of a map", func() error { _, err := list.GetArrayListE(2); return err }, func() { list.GetArrayList(2) }},
+		{"GetArrayList of nil", func() error { _, err := list.GetArrayListE(4); return err }, func() { list.GetArrayList(4) }},
+		{"PromoteJSONObject of a string", func() error { _, err := list.PromoteJSONObject(1); return err }, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.get(); err == nil {
+				t.Error("no error")
+			}
+			if test.getOrPanic != nil && !mustPanic(test.getOrPanic) {
+				t.Error("the panicking getter didn't panic")
+			}
+		})
+	}
+	if list.GetBool(1) {
+		t.Error("GetBool of a string returned true")
+	}
+	if !mustPanic(func() { list.GetInt(list.Size()) }) {
+		t.Error("GetInt out of range didn't panic")
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	list := accessorsList()
+	list.Add(`[1,"a"]`)
+	if n := list.GetInt(0); n != 3 {
+		t.Errorf("GetInt = %d, want 3", n)
+	}
+	if s, err := list.GetStringE(0); s != "3" || err != nil {
+		t.Errorf("GetStringE = %q, %v, want 3", s, err)
+	}
+	if f := list.GetFloat(6); f != 2.5 {
+		t.Errorf("GetFloat = %v, want 2.5", f)
+	}
+	if !list.GetBool(5) {
+		t.Error("GetBool = false, want true")
+	}
+	obj := list.GetJSONObject(2)
+	if obj.GetInt("a") != 1 {
+		t.Errorf("GetJSONObject = %s", obj.ToJSONString())
+	}
+	if _, ok := list.Get(2).(map[string]interface{}); !ok {
+		t.Error("GetJSONObject replaced the stored map")
+	}
+	promoted, err := list.PromoteJSONObject(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Get(2) != promoted || list.GetJSONObject(2) != promoted {
+		t.Error("PromoteJSONObject didn't store the object")
+	}
+	if sub := list.GetArrayList(3); sub.Size() != 2 || sub.GetInt(1) != 2 {
+		t.Errorf("GetArrayList = %v", sub.GetArray())
+	}
+	if sub := list.GetArrayList(7); sub.Size() != 2 || sub.GetString(1) != "a" {
+		t.Errorf("GetArrayList of a json string = %v", sub.GetArray())
+	}
+}
+
+func isEven(val int) bool {
+	return val%2 == 0
+}
+
+func TestFunctional(t *testing.T) {
+	vals := []int{1, 2, 3, 4, 5}
+	empty := []int{}
+	if got := Filter(vals, isEven); !reflect.DeepEqual(got, []int{2, 4}) {
+		t.Errorf("Filter = %v", got)
+	}
+	if got := Filter(empty, isEven); got == nil || len(got) != 0 {
+		t.Errorf("Filter of an empty slice = %#v, want an empty slice", got)
+	}
+	if got := Map(vals, strconv.Itoa); !reflect.DeepEqual(got, []string{"1", "2", "3", "4", "5"}) {
+		t.Errorf("Map = %v", got)
+	}
+	if got := Reduce(vals, "", func(acc string, val int) string { return acc + strconv.Itoa(val) }); got != "12345" {
+		t.Errorf("Reduce = %s", got)
+	}
+	if got, ok := Find(vals, isEven); !ok || got != 2 {
+		t.Errorf("Find = %d, %v, want 2, true", got, ok)
+	}
+	if got, ok := Find(empty, isEven); ok || got != 0 {
+		t.Errorf("Find in an empty slice = %d, %v", got, ok)
+	}
+	if !Any(vals, isEven) || Any(empty, isEven) || Any([]int{1, 3}, isEven) {
+		t.Error("Any")
+	}
+	if All(vals, isEven) || !All(empty, isEven) || !All([]int{2, 4}, isEven) {
+		t.Error("All")
+	}
+	if got := GroupBy(vals, isEven); !reflect.DeepEqual(got, map[bool][]int{true: {2, 4}, false: {1, 3, 5}}) {
+		t.Errorf("GroupBy = %v", got)
+	}
+	if got := Distinct([]int{3, 1, 4, 1, 5, 9, 2, 6}, func(val int) int { return val % 3 }); !reflect.DeepEqual(got, []int{3, 1, 5}) {
+		t.Errorf("Distinct = %v", got)
+	}
+	matched, others := Partition(vals, isEven)
+	if !reflect.DeepEqual(matched, []int{2, 4}) || !reflect.DeepEqual(others, []int{1, 3, 5}) {
+		t.Errorf("Partition = %v, %v", matched, others)
+	}
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		size int
+		want [][]int
+	}{
+		{2, [][]int{{1, 2}, {3, 4}, {5}}},
+		{5, [][]int{{1, 2, 3, 4, 5}}},
+		{9, [][]int{{1, 2, 3, 4, 5}}},
+		{0, [][]int{{1}, {2}, {3}, {4}, {5}}},
+	}
+	for _, test := range tests {
+		vals := []int{1, 2, 3, 4, 5}
+		got := Chunk(vals, test.size)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Chunk(%d) = %v, want %v", test.size, got, test.want)
+		}
+		got[0][0] = 100
+		if vals[0] != 1 {
+			t.Errorf("Chunk(%d) shares its chunks with vals", test.size)
+		}
+	}
+	if got := Chunk([]int{}, 2); len(got) != 0 {
+		t.Errorf("Chunk of an empty slice = %v", got)
+	}
+}
+
+func TestArrayListFunctional(t *testing.T) {
+	list := NewArrayList()
+	for i := 1; i <= 5; i++ {
+		list.Add(i)
+	}
+	even := func(val interface{}) bool {
+		return val.(int)%2 == 0
+	}
+	if got := list.Filter(even).GetArray(); !reflect.DeepEqual(got, []interface{}{2, 4}) {
+		t.Errorf("Filter = %v", got)
+	}
+	if got := list.Map(func(val interface{}) interface{} { return val.(int) * 10 }).GetArray(); !reflect.DeepEqual(got, []interface{}{10, 20, 30, 40, 50}) {
+		t.Errorf("Map = %v", got)
+	}
+	if got := list.Reduce(0, func(acc interface{}, val interface{}) interface{} { return acc.(int) + val.(int) }); got != 15 {
+		t.Errorf("Reduce = %v", got)
+	}
+	if got, ok := list.Find(even); !ok || got != 2 {
+		t.Errorf("Find = %v, %v", got, ok)
+	}
+	if !list.Any(even) || list.All(even) {
+		t.Error("Any or All")
+	}
+	groups := list.GroupBy(func(val interface{}) string {
+		if even(val) {
+			return "even"
+		}
+		return "odd"
+	})
+	if len(groups) != 2 || !reflect.DeepEqual(groups["odd"].GetArray(), []interface{}{1, 3, 5}) {
+		t.Errorf("GroupBy = %v", groups)
+	}
+	if got := list.Distinct(func(val interface{}) string { return strconv.Itoa(val.(int) % 2) }).GetArray(); !reflect.DeepEqual(got, []interface{}{1, 2}) {
+		t.Errorf("Distinct = %v", got)
+	}
+	chunks := list.Chunk(2)
+	if len(chunks) != 3 || !reflect.DeepEqual(chunks[2].GetArray(), []interface{}{5}) {
+		t.Errorf("Chunk = %v", chunks)
+	}
+	matched, others := list.Partition(even)
+	if matched.Size() != 2 || others.Size() != 3 {
+		t.Errorf("Partition = %v, %v", matched.GetArray(), others.GetArray())
+	}
+
+	// the funcs run on a snapshot, so they may change the list and results don't share it
+	filtered := list.Filter(func(val interface{}) bool {
+		list.Add(0)
+		return true
+	})
+	if filtered.Size() != 5 || list.Size() != 10 {
+		t.Errorf("Filter changing the list: %d filtered, list has %d", filtered.Size(), list.Size())
+	}
+	filtered.RemoveAt(0)
+	if filtered.Size() != 4 || list.Get(0) != 1 {
+		t.Error("changing the result of Filter changed the list")
+	}
+}
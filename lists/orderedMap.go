@@ -0,0 +1,171 @@
+package lists
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// OrderedMap is a map remembering the insertion order of its keys, it is safe for concurrent use.
+// It is marshaled to json as an object in insertion order, non string keys are marshaled as json text.
+type OrderedMap[K comparable, V any] struct {
+	keys     []K
+	innerMap map[K]V
+	lock     sync.RWMutex
+}
+
+func NewOrderedMap[K comparable, V any]() *OrderedMap[K, V] {
+	return &OrderedMap[K, V]{innerMap: make(map[K]V)}
+}
+
+// Put sets the value of key, an existing key keeps its position.
+func (orderedMap *OrderedMap[K, V]) Put(key K, val V) {
+	orderedMap.lock.Lock()
+	defer orderedMap.lock.Unlock()
+	if orderedMap.innerMap == nil {
+		orderedMap.innerMap = make(map[K]V)
+	}
+	if _, ok := orderedMap.innerMap[key]; !ok {
+		orderedMap.keys = append(orderedMap.keys, key)
+	}
+	orderedMap.innerMap[key] = val
+}
+
+func (orderedMap *OrderedMap[K, V]) Get(key K) (V, bool) {
+	orderedMap.lock.RLock()
+	defer orderedMap.lock.RUnlock()
+	val, ok := orderedMap.innerMap[key]
+	return val, ok
+}
+
+func (orderedMap *OrderedMap[K, V]) Contains(key K) bool {
+	_, ok := orderedMap.Get(key)
+	return ok
+}
+
+func (orderedMap *OrderedMap[K, V]) Remove(key K) bool {
+	orderedMap.lock.Lock()
+	defer orderedMap.lock.Unlock()
+	if _, ok := orderedMap.innerMap[key]; !ok {
+		return false
+	}
+	delete(orderedMap.innerMap, key)
+	for idx, k := range orderedMap.keys {
+		if k == key {
+			orderedMap.keys = append(orderedMap.keys[:idx], orderedMap.keys[idx+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (orderedMap *OrderedMap[K, V]) Size() int {
+	orderedMap.lock.RLock()
+	defer orderedMap.lock.RUnlock()
+	return len(orderedMap.keys)
+}
+
+func (orderedMap *OrderedMap[K, V]) Clear() {
+	orderedMap.lock.Lock()
+	defer orderedMap.lock.Unlock()
+	orderedMap.keys = nil
+	orderedMap.innerMap = make(map[K]V)
+}
+
+func (orderedMap *OrderedMap[K, V]) Keys() []K {
+	orderedMap.lock.RLock()
+	defer orderedMap.lock.RUnlock()
+	res := make([]K, len(orderedMap.keys))
+	copy(res, orderedMap.keys)
+	return res
+}
+
+func (orderedMap *OrderedMap[K, V]) Values() []V {
+	orderedMap.lock.RLock()
+	defer orderedMap.lock.RUnlock()
+	res := make([]V, len(orderedMap.keys))
+	for i, key := range orderedMap.keys {
+		res[i] = orderedMap.innerMap[key]
+	}
+	return res
+}
+
+// Each iterates over a snapshot in insertion order, so f may modify the map.
+func (orderedMap *OrderedMap[K, V]) Each(f func(key K, val V)) {
+	orderedMap.lock.RLock()
+	keys := make([]K, len(orderedMap.keys))
+	vals := make([]V, len(orderedMap.keys))
+	for i, key := range orderedMap.keys {
+		keys[i] = key
+		vals[i] = orderedMap.innerMap[key]
+	}
+	orderedMap.lock.RUnlock()
+	for i, key := range keys {
+		f(key, vals[i])
+	}
+}
+
+func (orderedMap *OrderedMap[K, V]) MarshalJSON() ([]byte, error) {
+	orderedMap.lock.RLock()
+	defer orderedMap.lock.RUnlock()
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range orderedMap.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		var keyStr string
+		if s, ok := interface{}(key).(string); ok {
+			keyStr = s
+		} else {
+			b, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			keyStr = string(b)
+		}
+		b, _ := json.Marshal(keyStr)
+		buf.Write(b)
+		buf.WriteByte(':')
+		b, err := json.Marshal(orderedMap.innerMap[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (orderedMap *OrderedMap[K, V]) UnmarshalJSON(b []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return errors.New("OrderedMap must be unmarshaled from a json object")
+	}
+	orderedMap.Clear()
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		keyStr := token.(string)
+		var key K
+		if k, ok := interface{}(keyStr).(K); ok {
+			key = k
+		} else if err = json.Unmarshal([]byte(keyStr), &key); err != nil {
+			return err
+		}
+		var val V
+		if err = decoder.Decode(&val); err != nil {
+			return err
+		}
+		orderedMap.Put(key, val)
+	}
+	_, err = decoder.Token()
+	return err
+}
@@ -0,0 +1,138 @@
+package lists
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+type innerHeap[T any] struct {
+	vals []T
+	less func(a, b T) bool
+}
+
+func (h *innerHeap[T]) Len() int           { return len(h.vals) }
+func (h *innerHeap[T]) Less(i, j int) bool { return h.less(h.vals[i], h.vals[j]) }
+func (h *innerHeap[T]) Swap(i, j int)      { h.vals[i], h.vals[j] = h.vals[j], h.vals[i] }
+func (h *innerHeap[T]) Push(x interface{}) { h.vals = append(h.vals, x.(T)) }
+func (h *innerHeap[T]) Pop() interface{} {
+	n := len(h.vals)
+	val := h.vals[n-1]
+	var zero T
+	h.vals[n-1] = zero
+	h.vals = h.vals[:n-1]
+	return val
+}
+
+// PriorityQueue pops the smallest element according to less first, it is safe for concurrent use.
+// It is marshaled to json as an array in pop order. Unlike the other collections its zero
+// value has no less func, so it must be created by NewPriorityQueue, Push panics and
+// UnmarshalJSON returns an error on a zero value.
+type PriorityQueue[T any] struct {
+	h    *innerHeap[T]
+	lock sync.RWMutex
+}
+
+func NewPriorityQueue[T any](less func(a, b T) bool, vals ...T) *PriorityQueue[T] {
+	h := &innerHeap[T]{less: less}
+	h.vals = append(h.vals, vals...)
+	heap.Init(h)
+	return &PriorityQueue[T]{h: h}
+}
+
+var errNoLess = errors.New("PriorityQueue must be created by NewPriorityQueue")
+
+func (pq *PriorityQueue[T]) Push(val T) {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+	if pq.h == nil {
+		panic(errNoLess)
+	}
+	heap.Push(pq.h, val)
+}
+
+// Pop removes and returns the smallest element, ok is false if the queue is empty.
+func (pq *PriorityQueue[T]) Pop() (val T, ok bool) {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+	if pq.h == nil || pq.h.Len() == 0 {
+		return val, false
+	}
+	return heap.Pop(pq.h).(T), true
+}
+
+func (pq *PriorityQueue[T]) Peek() (val T, ok bool) {
+	pq.lock.RLock()
+	defer pq.lock.RUnlock()
+	if pq.h == nil || pq.h.Len() == 0 {
+		return val, false
+	}
+	return pq.h.vals[0], true
+}
+
+func (pq *PriorityQueue[T]) Size() int {
+	pq.lock.RLock()
+	defer pq.lock.RUnlock()
+	if pq.h == nil {
+		return 0
+	}
+	return pq.h.Len()
+}
+
+func (pq *PriorityQueue[T]) Clear() {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+	if pq.h != nil {
+		pq.h.vals = nil
+	}
+}
+
+// Values returns a copy of the elements in pop order.
+func (pq *PriorityQueue[T]) Values() []T {
+	pq.lock.RLock()
+	defer pq.lock.RUnlock()
+	if pq.h == nil {
+		return []T{}
+	}
+	res := make([]T, len(pq.h.vals))
+	copy(res, pq.h.vals)
+	sort.SliceStable(res, func(i, j int) bool {
+		return pq.h.less(res[i], res[j])
+	})
+	return res
+}
+
+func (pq *PriorityQueue[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pq.Values())
+}
+
+// UnmarshalJSON replaces the elements, the queue must be created by NewPriorityQueue first.
+func (pq *PriorityQueue[T]) UnmarshalJSON(b []byte) error {
+	if pq.h == nil {
+		return errNoLess
+	}
+	vals := make([]T, 0)
+	err := json.Unmarshal(b, &vals)
+	if err != nil {
+		return err
+	}
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+	pq.h.vals = vals
+	heap.Init(pq.h)
+	return nil
+}
+
+func (pq *PriorityQueue[T]) ToArrayList() *ArrayList {
+	return sliceToArrayList(pq.Values())
+}
+
+func PriorityQueueFromArrayList[T any](arrayList *ArrayList, less func(a, b T) bool) (*PriorityQueue[T], error) {
+	vals, err := arrayListToSlice[T](arrayList)
+	if err != nil {
+		return nil, err
+	}
+	return NewPriorityQueue(less, vals...), nil
+}
@@ -0,0 +1,136 @@
+package lists
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Set is a collection of unique elements, it is safe for concurrent use.
+// It is marshaled to json as an array in unspecified order.
+type Set[T comparable] struct {
+	innerMap map[T]struct{}
+	lock     sync.RWMutex
+}
+
+func NewSet[T comparable](vals ...T) *Set[T] {
+	set := &Set[T]{innerMap: make(map[T]struct{})}
+	for _, val := range vals {
+		set.innerMap[val] = struct{}{}
+	}
+	return set
+}
+
+// Add adds the elements and returns the number of elements newly added.
+func (set *Set[T]) Add(vals ...T) int {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	if set.innerMap == nil {
+		set.innerMap = make(map[T]struct{})
+	}
+	added := 0
+	for _, val := range vals {
+		if _, ok := set.innerMap[val]; !ok {
+			set.innerMap[val] = struct{}{}
+			added++
+		}
+	}
+	return added
+}
+
+func (set *Set[T]) Remove(val T) bool {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	_, ok := set.innerMap[val]
+	delete(set.innerMap, val)
+	return ok
+}
+
+func (set *Set[T]) Contains(val T) bool {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	_, ok := set.innerMap[val]
+	return ok
+}
+
+func (set *Set[T]) Size() int {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return len(set.innerMap)
+}
+
+func (set *Set[T]) Clear() {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+	set.innerMap = make(map[T]struct{})
+}
+
+func (set *Set[T]) Values() []T {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	res := make([]T, 0, len(set.innerMap))
+	for val := range set.innerMap {
+		res = append(res, val)
+	}
+	return res
+}
+
+func (set *Set[T]) Each(f func(val T)) {
+	for _, val := range set.Values() {
+		f(val)
+	}
+}
+
+func (set *Set[T]) Union(other *Set[T]) *Set[T] {
+	res := NewSet(set.Values()...)
+	res.Add(other.Values()...)
+	return res
+}
+
+func (set *Set[T]) Intersect(other *Set[T]) *Set[T] {
+	res := NewSet[T]()
+	for _, val := range set.Values() {
+		if other.Contains(val) {
+			res.Add(val)
+		}
+	}
+	return res
+}
+
+func (set *Set[T]) Difference(other *Set[T]) *Set[T] {
+	res := NewSet[T]()
+	for _, val := range set.Values() {
+		if !other.Contains(val) {
+			res.Add(val)
+		}
+	}
+	return res
+}
+
+func (set *Set[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(set.Values())
+}
+
+func (set *Set[T]) UnmarshalJSON(b []byte) error {
+	vals := make([]T, 0)
+	err := json.Unmarshal(b, &vals)
+	if err != nil {
+		return err
+	}
+	set.lock.Lock()
+	set.innerMap = make(map[T]struct{})
+	set.lock.Unlock()
+	set.Add(vals...)
+	return nil
+}
+
+func (set *Set[T]) ToArrayList() *ArrayList {
+	return sliceToArrayList(set.Values())
+}
+
+func SetFromArrayList[T comparable](arrayList *ArrayList) (*Set[T], error) {
+	vals, err := arrayListToSlice[T](arrayList)
+	if err != nil {
+		return nil, err
+	}
+	return NewSet(vals...), nil
+}
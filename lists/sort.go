@@ -0,0 +1,274 @@
+package lists
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wellmoon/go/zjson"
+)
+
+// SortField is a field of a json row used by SortByFields.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+func Asc(field string) SortField {
+	return SortField{Field: field}
+}
+
+func Desc(field string) SortField {
+	return SortField{Field: field, Desc: true}
+}
+
+// SortBy sorts the list in place, elements which are equal keep their order.
+func (arrayList *ArrayList) SortBy(less func(a interface{}, b interface{}) bool) {
+	arrayList.mutex.Lock()
+	defer arrayList.mutex.Unlock()
+	sort.SliceStable(arrayList.innerList, func(i, j int) bool {
+		return less(arrayList.innerList[i], arrayList.innerList[j])
+	})
+}
+
+// SortByValue sorts the list in place with CompareValues.
+func (arrayList *ArrayList) SortByValue() {
+	arrayList.SortBy(func(a interface{}, b interface{}) bool {
+		return CompareValues(a, b) < 0
+	})
+}
+
+// SortByFields sorts a list of json rows in place by several fields, values are compared
+// with CompareValues. Rows may be *zjson.JSONObject, map[string]interface{} or map[string]string.
+//
+// sample
+// list.SortByFields(lists.Desc("price"), lists.Asc("name"))
+func (arrayList *ArrayList) SortByFields(fields ...SortField) {
+	arrayList.SortBy(func(a interface{}, b interface{}) bool {
+		for _, field := range fields {
+			res := CompareValues(fieldValue(a, field.Field), fieldValue(b, field.Field))
+			if res == 0 {
+				continue
+			}
+			if field.Desc {
+				return res > 0
+			}
+			return res < 0
+		}
+		return false
+	})
+}
+
+// BinarySearch searches target in a list sorted by SortByValue, it returns the index of
+// target if found, otherwise the index where target would be inserted.
+func (arrayList *ArrayList) BinarySearch(target interface{}) (int, bool) {
+	return arrayList.BinarySearchFunc(func(val interface{}) int {
+		return CompareValues(val, target)
+	})
+}
+
+// BinarySearchFunc searches a sorted list, cmp returns a negative number if val is before
+// the target, zero if val is the target and a positive number if val is after it.
+func (arrayList *ArrayList) BinarySearchFunc(cmp func(val interface{}) int) (int, bool) {
+	arrayList.mutex.RLock()
+	defer arrayList.mutex.RUnlock()
+	idx := sort.Search(len(arrayList.innerList), func(i int) bool {
+		return cmp(arrayList.innerList[i]) >= 0
+	})
+	return idx, idx < len(arrayList.innerList) && cmp(arrayList.innerList[idx]) == 0
+}
+
+// SortBy sorts the list in place, elements which are equal keep their order.
+func (list *List[T]) SortBy(less func(a T, b T) bool) {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	sort.SliceStable(list.innerList, func(i, j int) bool {
+		return less(list.innerList[i], list.innerList[j])
+	})
+}
+
+func fieldValue(row interface{}, field string) interface{} {
+	switch value := row.(type) {
+	case *zjson.JSONObject:
+		return value.Get(field)
+	case map[string]interface{}:
+		return value[field]
+	case map[string]string:
+		v, ok := value[field]
+		if !ok {
+			return nil
+		}
+		return v
+	default:
+		return nil
+	}
+}
+
+// Kinds of values in the order used by CompareValues.
+const (
+	rankNil = iota
+	rankBool
+	rankNumber
+	rankString
+)
+
+func valueRank(val interface{}) int {
+	if val == nil {
+		return rankNil
+	}
+	if _, ok := val.(bool); ok {
+		return rankBool
+	}
+	if _, ok := toNumber(val); ok {
+		return rankNumber
+	}
+	return rankString
+}
+
+// CompareValues compares a and b and returns -1, 0 or 1. Values are ordered by kind first,
+// nil before bools before numbers before other values. false is before true, numbers and
+// numeric strings are compared by value, and other values are compared as strings with
+// digit sequences compared by value, so "item2" is before "item10". This is a total order,
+// so "1.10" is before "1.5" and both are before "1.5a".
+func CompareValues(a interface{}, b interface{}) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return compareOrdered(ra, rb)
+	}
+	switch ra {
+	case rankNil:
+		return 0
+	case rankBool:
+		return compareOrdered(boolToInt(a.(bool)), boolToInt(b.(bool)))
+	case rankNumber:
+		if ai, aok := toInteger(a); aok {
+			if bi, bok := toInteger(b); bok {
+				return compareOrdered(ai, bi)
+			}
+		}
+		af, _ := toNumber(a)
+		bf, _ := toNumber(b)
+		return compareFloats(af, bf)
+	}
+	as, bs := zjson.ToStr(a), zjson.ToStr(b)
+	if res := naturalCompare(as, bs); res != 0 {
+		return res
+	}
+	// "a01" and "a1" are equal for naturalCompare, keep them in a fixed order
+	return strings.Compare(as, bs)
+}
+
+// compareFloats compares a and b with NaN before every other number.
+func compareFloats(a float64, b float64) int {
+	aNaN, bNaN := math.IsNaN(a), math.IsNaN(b)
+	if aNaN || bNaN {
+		return compareOrdered(boolToInt(!aNaN), boolToInt(!bNaN))
+	}
+	return compareOrdered(a, b)
+}
+
+func compareOrdered[T int64 | float64 | int](a T, b T) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func toInteger(val interface{}) (int64, bool) {
+	switch value := val.(type) {
+	case int:
+		return int64(value), true
+	case int8:
+		return int64(value), true
+	case int16:
+		return int64(value), true
+	case int32:
+		return int64(value), true
+	case int64:
+		return value, true
+	case uint8:
+		return int64(value), true
+	case uint16:
+		return int64(value), true
+	case uint32:
+		return int64(value), true
+	case json.Number:
+		i, err := value.Int64()
+		return i, err == nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toNumber(val interface{}) (float64, bool) {
+	switch value := val.(type) {
+	case float32:
+		return float64(value), true
+	case float64:
+		return value, true
+	case uint:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case string:
+		// "NaN" and "Inf" are words rather than numbers
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	}
+	if i, ok := toInteger(val); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func naturalCompare(a string, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			si := i
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			sj := j
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			na := strings.TrimLeft(a[si:i], "0")
+			nb := strings.TrimLeft(b[sj:j], "0")
+			if len(na) != len(nb) {
+				return compareOrdered(len(na), len(nb))
+			}
+			if res := strings.Compare(na, nb); res != 0 {
+				return res
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return compareOrdered(int(a[i]), int(b[j]))
+		}
+		i++
+		j++
+	}
+	return compareOrdered(len(a)-i, len(b)-j)
+}
@@ -0,0 +1,213 @@
+package pool
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression, see ParseCron.
+type CronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	// domStar and dowStar are set when the day fields are *, a day then matches if both
+	// fields match, otherwise if either of the restricted fields matches like in cron
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField = cronField{0, 59, nil}
+	minuteField = cronField{0, 59, nil}
+	hourField   = cronField{0, 23, nil}
+	domField    = cronField{1, 31, nil}
+	monthField  = cronField{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = cronField{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseCron parses a cron expression with 5 fields "minute hour day-of-month month
+// day-of-week" or 6 fields with a leading second. Fields accept *, lists a,b, ranges a-b,
+// steps */n and a-b/n, month and weekday names like JAN and MON, and 0 or 7 for Sunday.
+// @yearly, @monthly, @weekly, @daily and @hourly are accepted too.
+//
+// sample
+//
+//	ParseCron("*/5 * * * *")      // every 5 minutes
+//	ParseCron("0 30 9 * * MON-FRI") // 9:30:00 on weekdays
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if descriptor, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = descriptor
+	}
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron expression %q needs 5 or 6 fields", expr)
+	}
+	schedule := &CronSchedule{}
+	var err error
+	parsers := []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&schedule.second, secondField},
+		{&schedule.minute, minuteField},
+		{&schedule.hour, hourField},
+		{&schedule.dom, domField},
+		{&schedule.month, monthField},
+		{&schedule.dow, dowField},
+	}
+	for i, parser := range parsers {
+		if *parser.bits, err = parseCronField(fields[i], parser.field); err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", expr, err)
+		}
+	}
+	// Sunday is 0 and 7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.domStar = fields[3] == "*" || fields[3] == "?"
+	schedule.dowStar = fields[5] == "*" || fields[5] == "?"
+	return schedule, nil
+}
+
+func parseCronField(text string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(text, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			part = part[:idx]
+		}
+		var from, to int
+		switch {
+		case part == "*" || part == "?":
+			from, to = field.min, field.max
+		case strings.Contains(part, "-"):
+			idx := strings.Index(part, "-")
+			var err error
+			if from, err = cronValue(part[:idx], field); err != nil {
+				return 0, err
+			}
+			if to, err = cronValue(part[idx+1:], field); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if from, err = cronValue(part, field); err != nil {
+				return 0, err
+			}
+			to = from
+			if step > 1 {
+				to = field.max
+			}
+		}
+		if from > to {
+			return 0, fmt.Errorf("bad range %q", part)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(text string, field cronField) (int, error) {
+	if v, ok := field.names[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("bad value %q", text)
+	}
+	if v < field.min || v > field.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, field.min, field.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t matching the schedule, in the location of t. It
+// returns the zero time if nothing matches within 5 years, like for February 30.
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + 5
+	// truncated is set once the smaller fields were reset to their minimum
+	truncated := false
+	for t.Year() <= yearLimit {
+		if schedule.month&(1<<uint(t.Month())) == 0 {
+			if !truncated {
+				truncated = true
+				t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+			}
+			t = t.AddDate(0, 1, 0)
+			continue
+		}
+		if !schedule.dayMatches(t) {
+			if !truncated {
+				truncated = true
+				t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+			}
+			t = t.AddDate(0, 0, 1)
+			continue
+		}
+		if schedule.hour&(1<<uint(t.Hour())) == 0 {
+			if !truncated {
+				truncated = true
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+			}
+			t = t.Add(time.Hour)
+			continue
+		}
+		if schedule.minute&(1<<uint(t.Minute())) == 0 {
+			if !truncated {
+				truncated = true
+				t = t.Truncate(time.Minute)
+			}
+			t = t.Add(time.Minute)
+			continue
+		}
+		if schedule.second&(1<<uint(t.Second())) == 0 {
+			truncated = true
+			t = t.Add(time.Second)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (schedule *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := schedule.dom&(1<<uint(t.Day())) != 0
+	dowMatch := schedule.dow&(1<<uint(t.Weekday())) != 0
+	if schedule.domStar || schedule.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,170 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCancelled = errors.New("future cancelled")
+var ErrTimeout = errors.New("future timed out")
+
+// Future is the pending result of a task submitted with Submit or SubmitContext.
+type Future[T any] struct {
+	done   chan struct{}
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	value  T
+	err    error
+}
+
+func newFuture[T any]() *Future[T] {
+	future := &Future[T]{done: make(chan struct{})}
+	future.ctx, future.cancel = context.WithCancel(context.Background())
+	return future
+}
+
+// Submit runs f in the pool and returns its future, it blocks like Run while the wait
+// queue is full or applies the reject policy. The future fails with ErrShutdown if the
+// pool is shut down and with ErrRejected if the reject policy drops the task.
+func Submit[T any](p *Zpool, f func() (T, error)) *Future[T] {
+	return SubmitContext(p, func(ctx context.Context) (T, error) {
+		return f()
+	})
+}
+
+// SubmitContext is like Submit, ctx is cancelled when the future is cancelled so a long
+// running f can stop early.
+func SubmitContext[T any](p *Zpool, f func(ctx context.Context) (T, error)) *Future[T] {
+	future, zf := newFutureTask(p, f)
+	future.fail(p.submit(zf))
+	return future
+}
+
+// SubmitWithPriority is like Submit, the task runs before waiting tasks of a lower priority.
+func SubmitWithPriority[T any](p *Zpool, priority Priority, f func() (T, error)) *Future[T] {
+	future, zf := newFutureTask(p, func(ctx context.Context) (T, error) {
+		return f()
+	})
+	var err error
+	if zf.priority, err = checkPriority(priority); err != nil {
+		future.fail(err)
+		return future
+	}
+	future.fail(p.submit(zf))
+	return future
+}
+
+// SubmitKeyed is like Submit, tasks with the same key run one after another in the order
+// they were submitted, see RunKeyed.
+func SubmitKeyed[T any](p *Zpool, key string, f func() (T, error)) *Future[T] {
+	future, zf := newFutureTask(p, func(ctx context.Context) (T, error) {
+		return f()
+	})
+	future.fail(p.submitKeyed(key, zf))
+	return future
+}
+
+// newFutureTask returns the future of f and the task completing it. The task returns the
+// error of f or the *PanicError of its panic, so the stats count it as failed.
+func newFutureTask[T any](p *Zpool, f func(ctx context.Context) (T, error)) (*Future[T], *zfunc) {
+	future := newFuture[T]()
+	zf, _ := newZfunc(func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicError := newPanicError(r)
+				var zero T
+				future.complete(zero, panicError)
+				p.handlePanic(panicError)
+				err = panicError
+			}
+		}()
+		value, err := f(future.ctx)
+		future.complete(value, err)
+		return err
+	}, nil)
+	zf.onDrop = future.fail
+	zf.cancelled = func() bool {
+		return future.ctx.Err() != nil
+	}
+	return future, zf
+}
+
+// fail completes the future with err unless err is nil.
+func (future *Future[T]) fail(err error) {
+	if err != nil {
+		var zero T
+		future.complete(zero, err)
+	}
+}
+
+func (future *Future[T]) complete(value T, err error) bool {
+	completed := false
+	future.once.Do(func() {
+		future.value = value
+		future.err = err
+		completed = true
+		future.cancel()
+		close(future.done)
+	})
+	return completed
+}
+
+// Done is closed when the task finished or the future was cancelled.
+func (future *Future[T]) Done() <-chan struct{} {
+	return future.done
+}
+
+// Get waits for the task and returns its result, ErrCancelled after Cancel and a
+// *PanicError if the task panicked.
+func (future *Future[T]) Get() (T, error) {
+	<-future.done
+	return future.value, future.err
+}
+
+// GetWithTimeout is like Get but returns ErrTimeout if the task doesn't finish within timeout.
+func (future *Future[T]) GetWithTimeout(timeout time.Duration) (T, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-future.done:
+		return future.value, future.err
+	case <-timer.C:
+		var zero T
+		return zero, ErrTimeout
+	}
+}
+
+// Cancel completes the future with ErrCancelled. A task which didn't start yet is skipped,
+// a running task gets its context cancelled and its result is dropped. Cancel returns false
+// if the future was already done.
+func (future *Future[T]) Cancel() bool {
+	var zero T
+	return future.complete(zero, ErrCancelled)
+}
+
+func (future *Future[T]) IsCancelled() bool {
+	select {
+	case <-future.done:
+		return future.err == ErrCancelled
+	default:
+		return false
+	}
+}
+
+// InvokeAll submits tasks, waits for all of them and returns their results in the order of
+// tasks, errs[i] is the error of tasks[i].
+func InvokeAll[T any](p *Zpool, tasks ...func() (T, error)) ([]T, []error) {
+	futures := make([]*Future[T], len(tasks))
+	for i, task := range tasks {
+		futures[i] = Submit(p, task)
+	}
+	values := make([]T, len(tasks))
+	errs := make([]error, len(tasks))
+	for i, future := range futures {
+		values[i], errs[i] = future.Get()
+	}
+	return values, errs
+}
@@ -0,0 +1,104 @@
+package pool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RunKeyed is like Run but tasks with the same key run one after another in the order they
+// were submitted, while tasks with different keys run in parallel. The tasks waiting for
+// an earlier task of their key are kept outside the wait queue, so they are not limited
+// by its size, and the tasks of a key run on the same worker until none is left.
+func (p *Zpool) RunKeyed(key string, f interface{}, params ...interface{}) error {
+	zf, err := newZfunc(f, params)
+	if err != nil {
+		return err
+	}
+	return p.submitKeyed(key, zf)
+}
+
+func (p *Zpool) submitKeyed(key string, zf *zfunc) error {
+	if p.IsShutdown() {
+		p.countRejected(zf)
+		return ErrShutdown
+	}
+	zf.queuedAt = time.Now()
+	p.keyLock.Lock()
+	// check again under keyLock, ShutdownNow drops the waiting tasks under it after closing
+	if p.IsShutdown() {
+		p.keyLock.Unlock()
+		p.countRejected(zf)
+		return ErrShutdown
+	}
+	if pending, ok := p.keyed[key]; ok {
+		p.keyed[key] = append(pending, zf)
+		p.keyLock.Unlock()
+		p.countSubmitted(zf)
+		return nil
+	}
+	p.keyed[key] = nil
+	p.keyLock.Unlock()
+
+	runner, _ := newZfunc(func() {
+		for next := zf; next != nil; next = p.nextKeyed(key) {
+			next.invoke(p)
+		}
+	}, nil)
+	runner.priority = zf.priority
+	runner.internal = true
+	var dropped int32
+	runner.onDrop = func(err error) {
+		atomic.StoreInt32(&dropped, 1)
+		p.reject(zf, err)
+		p.keyLock.Lock()
+		pending := p.keyed[key]
+		delete(p.keyed, key)
+		p.keyLock.Unlock()
+		for _, next := range pending {
+			p.reject(next, err)
+		}
+	}
+	err := p.submit(runner)
+	if err != nil {
+		runner.onDrop(err)
+		return err
+	}
+	if atomic.LoadInt32(&dropped) == 0 {
+		p.countSubmitted(zf)
+	}
+	return nil
+}
+
+// nextKeyed returns the next task of key, or nil after forgetting key when none is left.
+// After ShutdownNow it returns nil and the tasks left are dropped, ShutdownNow returns them.
+func (p *Zpool) nextKeyed(key string) *zfunc {
+	p.keyLock.Lock()
+	defer p.keyLock.Unlock()
+	pending := p.keyed[key]
+	if p.isStopped() {
+		delete(p.keyed, key)
+		for _, zf := range pending {
+			p.drop(zf)
+		}
+		return nil
+	}
+	if len(pending) == 0 {
+		delete(p.keyed, key)
+		return nil
+	}
+	p.keyed[key] = pending[1:]
+	return pending[0]
+}
+
+// dropKeyed drops the waiting tasks of every key, the running tasks of the keys finish but
+// the tasks submitted after them don't start.
+func (p *Zpool) dropKeyed() {
+	p.keyLock.Lock()
+	defer p.keyLock.Unlock()
+	for key, pending := range p.keyed {
+		for _, zf := range pending {
+			p.drop(zf)
+		}
+		p.keyed[key] = nil
+	}
+}
@@ -1,80 +1,544 @@
 package pool
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	Log "github.com/wellmoon/go/logger"
+)
+
+// PanicError describes a task which panicked, Stack is the stack trace of the panic.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (panicError *PanicError) Error() string {
+	return fmt.Sprintf("pool task panic: %v", panicError.Value)
+}
+
+func newPanicError(value interface{}) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+func logPanic(panicError *PanicError) {
+	Log.Error("{}\n{}", panicError, string(panicError.Stack))
+}
+
+var ErrShutdown = errors.New("pool is shut down")
+var ErrRejected = errors.New("pool wait queue is full")
+
+// RejectPolicy decides what Run does when the wait queue is full.
+type RejectPolicy int
+
+const (
+	// RejectBlock waits until there is room in the queue, the default
+	RejectBlock RejectPolicy = iota
+	// RejectDropNewest drops the new task
+	RejectDropNewest
+	// RejectDropOldest drops the oldest waiting task and queues the new one
+	RejectDropOldest
+	// RejectCallerRuns runs the new task in the goroutine calling Run
+	RejectCallerRuns
+	// RejectError returns ErrRejected
+	RejectError
+)
+
+// Priority orders the waiting tasks, a task waits until no task of a higher priority is
+// waiting. Each priority has its own wait queue of the size given to the constructor.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	priorityNum
 )
 
 type zfunc struct {
-	f interface{}
-	p []interface{}
+	f        reflect.Value
+	p        []reflect.Value
+	priority Priority
+	// onDrop is called with the reason when the task is dropped without running
+	onDrop func(err error)
+	// internal tasks only run other tasks and are not counted in the stats
+	internal bool
+	// cancelled returns true if the task must be skipped, it counts as rejected then
+	cancelled func() bool
+	queuedAt  time.Time
+}
+
+func (zf *zfunc) drop(err error) {
+	if zf.onDrop != nil {
+		zf.onDrop(err)
+	}
+}
+
+// newZfunc checks that f is a func which accepts params.
+func newZfunc(f interface{}, params []interface{}) (*zfunc, error) {
+	funcValue := reflect.ValueOf(f)
+	if funcValue.Kind() != reflect.Func || funcValue.IsNil() {
+		return nil, fmt.Errorf("pool task %T is not a func", f)
+	}
+	funcType := funcValue.Type()
+	numIn := funcType.NumIn()
+	if funcType.IsVariadic() {
+		if len(params) < numIn-1 {
+			return nil, fmt.Errorf("pool task %s needs at least %d params, got %d", funcType, numIn-1, len(params))
+		}
+	} else if len(params) != numIn {
+		return nil, fmt.Errorf("pool task %s needs %d params, got %d", funcType, numIn, len(params))
+	}
+	paramList := make([]reflect.Value, len(params))
+	for i, param := range params {
+		var in reflect.Type
+		if funcType.IsVariadic() && i >= numIn-1 {
+			in = funcType.In(numIn - 1).Elem()
+		} else {
+			in = funcType.In(i)
+		}
+		if param == nil {
+			switch in.Kind() {
+			case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
+				paramList[i] = reflect.Zero(in)
+				continue
+			}
+			return nil, fmt.Errorf("pool task %s param %d is nil, want %s", funcType, i, in)
+		}
+		value := reflect.ValueOf(param)
+		if !value.Type().AssignableTo(in) {
+			return nil, fmt.Errorf("pool task %s param %d is %s, want %s", funcType, i, value.Type(), in)
+		}
+		paramList[i] = value
+	}
+	return &zfunc{f: funcValue, p: paramList, priority: PriorityNormal}, nil
 }
 
-func (zf *zfunc) invoke() {
-	kind := reflect.TypeOf(zf.f).Kind()
-	if kind == reflect.Func {
-		funcValue := reflect.ValueOf(zf.f)
-		paramList := make([]reflect.Value, 0)
-		for _, inter := range zf.p {
-			paramList = append(paramList, reflect.ValueOf(inter))
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// invoke calls the func, a panic is recovered and passed to the panic handler of p.
+func (zf *zfunc) invoke(p *Zpool) {
+	if zf.cancelled != nil && zf.cancelled() {
+		p.countRejected(zf)
+		return
+	}
+	start := time.Now()
+	if !zf.internal {
+		p.stats.waitTime.observe(start.Sub(zf.queuedAt))
+	}
+	failed := true
+	defer func() {
+		if r := recover(); r != nil {
+			p.handlePanic(newPanicError(r))
 		}
-		funcValue.Call(paramList)
+		if !zf.internal {
+			p.stats.execTime.observe(time.Since(start))
+			atomic.AddInt64(&p.stats.completed, 1)
+			if failed {
+				atomic.AddInt64(&p.stats.failed, 1)
+			}
+		}
+	}()
+	failed = returnsError(zf.f.Call(zf.p))
+}
+
+// returnsError returns true if the last result is a non nil error.
+func returnsError(results []reflect.Value) bool {
+	if len(results) == 0 {
+		return false
+	}
+	last := results[len(results)-1]
+	if !last.Type().Implements(errorType) {
+		return false
+	}
+	switch last.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return !last.IsNil()
 	}
+	return true
 }
 
 type Zpool struct {
-	waitCh chan *zfunc
-	curCh  chan int8
+	// waitChs holds a wait queue per priority
+	waitChs [priorityNum]chan *zfunc
+	// taskCh hands a task from the dispatcher to an idle worker
+	taskCh chan *zfunc
+	// wakeCh wakes the dispatcher waiting for a worker when a worker exits or the
+	// worker limits change
+	wakeCh chan struct{}
+
+	coreWorkers int32
+	maxWorkers  int32
+	idleTimeout int64
+	workerNum   int32
+	busyNum     int32
+	workers     sync.WaitGroup
+
+	lock         sync.RWMutex
+	panicHandler func(panicError *PanicError)
+	rejectPolicy RejectPolicy
+
+	// submitLock is held for reading while a task is sent to a wait queue, so shutdown
+	// can close the queues once no send is in progress
+	submitLock sync.RWMutex
+	closing    chan struct{}
+	closeOnce  sync.Once
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+	exited     chan struct{}
+
+	// keyed holds the waiting tasks of every key with a running task
+	keyLock sync.Mutex
+	keyed   map[string][]*zfunc
+
+	stats   *poolStats
+	dropped []*zfunc
 }
 
+const DefaultIdleTimeout = time.Minute
+
 func New() *Zpool {
-	p := &Zpool{}
-	p.curCh = make(chan int8, runtime.NumCPU()*2)
-	p.waitCh = make(chan *zfunc, runtime.NumCPU()*8)
-	p.start()
-	return p
+	return NewWithNum(runtime.NumCPU()*2, runtime.NumCPU()*8)
 }
 
+// NewWithNum returns a pool running at most maxGoroutineNum tasks at once, workers are
+// started on demand and exit after DefaultIdleTimeout without work.
 func NewWithNum(maxGoroutineNum int, waitQueueNum int) *Zpool {
+	return NewWithWorkers(0, maxGoroutineNum, waitQueueNum, DefaultIdleTimeout)
+}
+
+// NewWithWorkers returns a pool with at most maxWorkers workers. Workers are reused for
+// the next task, a worker idle for idleTimeout exits unless it is one of the first
+// coreWorkers workers, those live until the pool is shut down.
+func NewWithWorkers(coreWorkers int, maxWorkers int, waitQueueNum int, idleTimeout time.Duration) *Zpool {
 	p := &Zpool{}
-	p.curCh = make(chan int8, maxGoroutineNum)
-	p.waitCh = make(chan *zfunc, waitQueueNum)
+	p.SetMaxWorkers(maxWorkers)
+	p.SetCoreWorkers(coreWorkers)
+	p.SetIdleTimeout(idleTimeout)
+	for i := range p.waitChs {
+		p.waitChs[i] = make(chan *zfunc, waitQueueNum)
+	}
+	p.taskCh = make(chan *zfunc)
+	p.keyed = make(map[string][]*zfunc)
+	p.stats = newPoolStats()
+	p.wakeCh = make(chan struct{}, 1)
+	p.closing = make(chan struct{})
+	p.stopCh = make(chan struct{})
+	p.exited = make(chan struct{})
 	p.start()
 	return p
 }
+
+// SetPanicHandler sets the func called with every panic recovered from a task, including
+// tasks of futures. The default handler logs the panic and its stack with logger.Error,
+// nil restores it.
+func (p *Zpool) SetPanicHandler(handler func(panicError *PanicError)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.panicHandler = handler
+}
+
+func (p *Zpool) handlePanic(panicError *PanicError) {
+	p.lock.RLock()
+	handler := p.panicHandler
+	p.lock.RUnlock()
+	if handler == nil {
+		handler = logPanic
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			logPanic(newPanicError(r))
+		}
+	}()
+	handler(panicError)
+}
+
+// SetRejectPolicy sets what Run does when the wait queue is full.
+func (p *Zpool) SetRejectPolicy(policy RejectPolicy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.rejectPolicy = policy
+}
+
+// GetCurNum returns the number of running tasks.
 func (p *Zpool) GetCurNum() int {
-	return len(p.curCh)
+	return int(atomic.LoadInt32(&p.busyNum))
 }
 
+// GetWaitNum returns the number of waiting tasks of all priorities.
 func (p *Zpool) GetWaitNum() int {
-	return len(p.waitCh)
+	num := 0
+	for _, waitCh := range p.waitChs {
+		num += len(waitCh)
+	}
+	return num
 }
 
+// ClearWait drops the waiting tasks, they count as rejected and their futures are cancelled.
 func (p *Zpool) ClearWait() {
-	for len(p.waitCh) > 0 {
-		<-p.waitCh
+	for _, waitCh := range p.waitChs {
+		for len(waitCh) > 0 {
+			select {
+			case zf, ok := <-waitCh:
+				if ok {
+					p.reject(zf, ErrCancelled)
+				}
+			default:
+			}
+		}
 	}
 }
 
-func (p *Zpool) Run(f interface{}, params ...interface{}) {
-	zf := &zfunc{}
-	zf.f = f
-	zf.p = params
-	p.waitCh <- zf
+// Run queues f to be called with params, it returns an error without queueing if f is not
+// a func or params don't match its signature, and ErrShutdown after Shutdown. When the
+// wait queue is full the reject policy applies.
+func (p *Zpool) Run(f interface{}, params ...interface{}) error {
+	zf, err := newZfunc(f, params)
+	if err != nil {
+		return err
+	}
+	return p.submit(zf)
 }
 
-func (p *Zpool) start() {
-	go func() {
-		for {
-			zf := <-p.waitCh
-			p.curCh <- 1 // occupy a goroutine
-			go func() {
-				zf.invoke()
-				_, ok := <-p.curCh // release a goroutine
-				if !ok {
-					panic("pick from curCh error")
-				}
-			}()
+// RunWithPriority is like Run, the task runs before waiting tasks of a lower priority.
+func (p *Zpool) RunWithPriority(priority Priority, f interface{}, params ...interface{}) error {
+	zf, err := newZfunc(f, params)
+	if err != nil {
+		return err
+	}
+	if zf.priority, err = checkPriority(priority); err != nil {
+		return err
+	}
+	return p.submit(zf)
+}
+
+func checkPriority(priority Priority) (Priority, error) {
+	if priority < PriorityLow || priority >= priorityNum {
+		return PriorityNormal, fmt.Errorf("pool priority %d is unknown", priority)
+	}
+	return priority, nil
+}
+
+// TryRun is like Run but returns false instead of waiting when the wait queue is full,
+// the reject policy is not used.
+func (p *Zpool) TryRun(f interface{}, params ...interface{}) (bool, error) {
+	zf, err := newZfunc(f, params)
+	if err != nil {
+		return false, err
+	}
+	ok, err := p.offer(zf, 0)
+	if !ok && err == nil {
+		p.countRejected(zf)
+	}
+	return ok, err
+}
+
+// RunWithTimeout is like Run but waits at most timeout for room in the wait queue and
+// returns ErrRejected after that, the reject policy is not used.
+func (p *Zpool) RunWithTimeout(timeout time.Duration, f interface{}, params ...interface{}) error {
+	zf, err := newZfunc(f, params)
+	if err != nil {
+		return err
+	}
+	ok, err := p.offer(zf, timeout)
+	if err == nil && !ok {
+		p.countRejected(zf)
+		err = ErrRejected
+	}
+	return err
+}
+
+func (p *Zpool) submit(zf *zfunc) error {
+	p.lock.RLock()
+	policy := p.rejectPolicy
+	p.lock.RUnlock()
+	if policy == RejectBlock {
+		_, err := p.offer(zf, -1)
+		return err
+	}
+	if policy == RejectDropOldest {
+		return p.offerDropOldest(zf)
+	}
+	ok, err := p.offer(zf, 0)
+	if ok || err != nil {
+		return err
+	}
+	// the queue may have been closed since offer, a shut down pool rejects the task
+	// whatever the policy
+	if p.IsShutdown() {
+		p.countRejected(zf)
+		return ErrShutdown
+	}
+	switch policy {
+	case RejectCallerRuns:
+		zf.queuedAt = time.Now()
+		p.countSubmitted(zf)
+		zf.invoke(p)
+		return nil
+	case RejectError:
+		p.countRejected(zf)
+		return ErrRejected
+	default:
+		p.reject(zf, ErrRejected)
+		return nil
+	}
+}
+
+// offerDropOldest sends zf to the wait queue, dropping the oldest waiting tasks of its
+// priority to make room. It holds submitLock, so the queue can't be closed meanwhile, and
+// returns ErrShutdown without dropping anything if the pool is shutting down.
+func (p *Zpool) offerDropOldest(zf *zfunc) error {
+	p.submitLock.RLock()
+	defer p.submitLock.RUnlock()
+	zf.queuedAt = time.Now()
+	for {
+		select {
+		case <-p.closing:
+			p.countRejected(zf)
+			return ErrShutdown
+		default:
 		}
+		select {
+		case p.waitChs[zf.priority] <- zf:
+			p.countSubmitted(zf)
+			return nil
+		default:
+		}
+		select {
+		case oldest := <-p.waitChs[zf.priority]:
+			p.reject(oldest, ErrRejected)
+		default:
+			if cap(p.waitChs[zf.priority]) == 0 {
+				// nothing to drop, the queue has no room at all
+				p.reject(zf, ErrRejected)
+				return nil
+			}
+		}
+	}
+}
+
+// offer sends zf to the wait queue, waiting up to wait for room, forever if wait < 0.
+func (p *Zpool) offer(zf *zfunc, wait time.Duration) (bool, error) {
+	p.submitLock.RLock()
+	defer p.submitLock.RUnlock()
+	select {
+	case <-p.closing:
+		p.countRejected(zf)
+		return false, ErrShutdown
+	default:
+	}
+	zf.queuedAt = time.Now()
+	select {
+	case p.waitChs[zf.priority] <- zf:
+		p.countSubmitted(zf)
+		return true, nil
+	default:
+		if wait == 0 {
+			return false, nil
+		}
+	}
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case p.waitChs[zf.priority] <- zf:
+		p.countSubmitted(zf)
+		return true, nil
+	case <-p.closing:
+		p.countRejected(zf)
+		return false, ErrShutdown
+	case <-timeout:
+		return false, nil
+	}
+}
+
+// IsShutdown returns true once Shutdown or ShutdownNow was called.
+func (p *Zpool) IsShutdown() bool {
+	select {
+	case <-p.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeQueue rejects new tasks and closes the wait queues, the dispatcher exits after
+// taking the tasks left in them.
+func (p *Zpool) closeQueue() {
+	p.closeOnce.Do(func() {
+		close(p.closing)
+		p.submitLock.Lock()
+		for _, waitCh := range p.waitChs {
+			close(waitCh)
+		}
+		p.submitLock.Unlock()
+	})
+}
+
+// Shutdown stops accepting tasks and waits until the waiting and running tasks finished or
+// ctx is done, in which case it returns ctx.Err() and the tasks go on in the background.
+func (p *Zpool) Shutdown(ctx context.Context) error {
+	p.closeQueue()
+	done := make(chan struct{})
+	go func() {
+		<-p.exited
+		p.workers.Wait()
+		close(done)
 	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ShutdownNow stops accepting tasks and returns the tasks which didn't start, in queue
+// order followed by the tasks waiting for their key, without waiting for the running ones.
+// Calling a returned func runs the task in the caller goroutine, a panic is recovered and
+// passed to the panic handler. The futures of the returned tasks complete only then.
+func (p *Zpool) ShutdownNow() []func() {
+	p.closeQueue()
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+	})
+	<-p.exited
+	p.dropKeyed()
+	p.lock.Lock()
+	dropped := p.dropped
+	p.dropped = nil
+	p.lock.Unlock()
+	tasks := make([]func(), len(dropped))
+	for i, zf := range dropped {
+		zf := zf
+		tasks[i] = func() {
+			zf.invoke(p)
+		}
+	}
+	return tasks
+}
+
+func (p *Zpool) isStopped() bool {
+	select {
+	case <-p.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Zpool) drop(zf *zfunc) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.dropped = append(p.dropped, zf)
 }
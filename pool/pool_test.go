@@ -0,0 +1,624 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitGoroutines waits until the number of goroutines is back to want.
+func waitGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		num := runtime.NumGoroutine()
+		if num <= want {
+			return
+		}
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines left, want %d\n%s", num, want, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShutdownLeavesNoGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+	p := NewWithWorkers(2, 4, 10, time.Minute)
+	var ran int32
+	for i := 0; i < 20; i++ {
+		if err := p.Run(func() {
+			atomic.AddInt32(&ran, 1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&ran); n != 20 {
+		t.Fatalf("%d tasks ran before Shutdown returned, want 20", n)
+	}
+	if err := p.Run(func() {}); err != ErrShutdown {
+		t.Fatalf("Run after Shutdown returned %v, want ErrShutdown", err)
+	}
+	waitGoroutines(t, before)
+}
+
+func TestShutdownNowLeavesNoGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+	p := NewWithWorkers(1, 1, 10, time.Minute)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := p.Run(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	for i := 0; i < 5; i++ {
+		if err := p.Run(func() {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tasks := p.ShutdownNow()
+	if len(tasks) != 5 {
+		t.Fatalf("ShutdownNow returned %d tasks, want 5", len(tasks))
+	}
+	close(release)
+	waitGoroutines(t, before)
+}
+
+func TestShutdownNowTaskPanicIsRecovered(t *testing.T) {
+	p := NewWithNum(1, 10)
+	var recovered int32
+	p.SetPanicHandler(func(panicError *PanicError) {
+		atomic.AddInt32(&recovered, 1)
+	})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := p.Run(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := p.Run(func() {
+		panic("boom")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tasks := p.ShutdownNow()
+	close(release)
+	if len(tasks) != 1 {
+		t.Fatalf("ShutdownNow returned %d tasks, want 1", len(tasks))
+	}
+	tasks[0]()
+	if n := atomic.LoadInt32(&recovered); n != 1 {
+		t.Fatalf("panic handler called %d times, want 1", n)
+	}
+}
+
+func TestShutdownNowDropsKeyedTasks(t *testing.T) {
+	p := NewWithNum(1, 10)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var ran int32
+	if err := p.RunKeyed("device", func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	for i := 0; i < 5; i++ {
+		if err := p.RunKeyed("device", func() {
+			atomic.AddInt32(&ran, 1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tasks := p.ShutdownNow()
+	close(release)
+	p.workers.Wait()
+	if n := atomic.LoadInt32(&ran); n != 0 {
+		t.Fatalf("%d keyed tasks ran after ShutdownNow", n)
+	}
+	if len(tasks) != 5 {
+		t.Fatalf("ShutdownNow returned %d tasks, want 5", len(tasks))
+	}
+	for _, task := range tasks {
+		task()
+	}
+	if n := atomic.LoadInt32(&ran); n != 5 {
+		t.Fatalf("%d returned tasks ran, want 5", n)
+	}
+}
+
+func TestScheduleRejectsInvalidDurations(t *testing.T) {
+	p := New()
+	defer p.ShutdownNow()
+	scheduler := NewScheduler(p)
+	defer scheduler.Stop()
+	f := func() {}
+	if _, err := scheduler.ScheduleAtFixedRate(0, 0, f); err == nil {
+		t.Error("ScheduleAtFixedRate accepted period 0")
+	}
+	if _, err := scheduler.ScheduleAtFixedRate(-time.Second, time.Second, f); err == nil {
+		t.Error("ScheduleAtFixedRate accepted a negative initial delay")
+	}
+	if _, err := scheduler.ScheduleAtFixedRate(0, time.Second, f, WithJitter(-time.Second)); err == nil {
+		t.Error("ScheduleAtFixedRate accepted a negative jitter")
+	}
+	if _, err := scheduler.ScheduleWithFixedDelay(0, -time.Second, f); err == nil {
+		t.Error("ScheduleWithFixedDelay accepted a negative delay")
+	}
+	if _, err := scheduler.ScheduleWithFixedDelay(-time.Second, time.Second, f); err == nil {
+		t.Error("ScheduleWithFixedDelay accepted a negative initial delay")
+	}
+	if _, err := scheduler.ScheduleWithFixedDelay(0, time.Second, f, WithJitter(-time.Second)); err == nil {
+		t.Error("ScheduleWithFixedDelay accepted a negative jitter")
+	}
+	if _, err := scheduler.Schedule(-time.Second, f); err == nil {
+		t.Error("Schedule accepted a negative delay")
+	}
+	if _, err := scheduler.Schedule(0, f, WithJitter(-time.Second)); err == nil {
+		t.Error("Schedule accepted a negative jitter")
+	}
+	if _, err := scheduler.ScheduleCron("* * * * * *", f, WithJitter(-time.Second)); err == nil {
+		t.Error("ScheduleCron accepted a negative jitter")
+	}
+	if _, err := scheduler.ScheduleCronSchedule(nil, f); err == nil {
+		t.Error("ScheduleCronSchedule accepted a nil schedule")
+	}
+	ran := make(chan struct{})
+	once, err := scheduler.Schedule(time.Millisecond, func() {
+		close(ran)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ran
+	<-once.Done()
+	task, err := scheduler.ScheduleAtFixedRate(0, time.Hour, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Cancel()
+	<-task.Done()
+}
+
+func TestRejectPoliciesAfterShutdown(t *testing.T) {
+	for _, policy := range []RejectPolicy{RejectBlock, RejectDropNewest, RejectDropOldest, RejectCallerRuns, RejectError} {
+		p := NewWithNum(1, 1)
+		p.SetRejectPolicy(policy)
+		p.ShutdownNow()
+		if err := p.Run(func() {}); err != ErrShutdown {
+			t.Errorf("policy %d: Run after shutdown returned %v, want ErrShutdown", policy, err)
+		}
+		if stats := p.Stats(); stats.Rejected != 1 || stats.Submitted != 0 {
+			t.Errorf("policy %d: stats %+v, want 1 rejected task", policy, stats)
+		}
+	}
+}
+
+func TestRejectDropOldest(t *testing.T) {
+	p := NewWithNum(1, 1)
+	p.SetRejectPolicy(RejectDropOldest)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := p.Run(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	// the dispatcher holds task 1 waiting for the worker, task 2 fills the queue and is
+	// dropped for task 3
+	var ran []int
+	result := make(chan int, 3)
+	for i := 1; i <= 3; i++ {
+		if err := p.Run(func(i int) {
+			result <- i
+		}, i); err != nil {
+			t.Fatal(err)
+		}
+		for i == 1 && p.GetWaitNum() > 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	close(release)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	close(result)
+	for i := range result {
+		ran = append(ran, i)
+	}
+	if len(ran) != 2 || ran[0] != 1 || ran[1] != 3 {
+		t.Fatalf("tasks %v ran, want [1 3]", ran)
+	}
+}
+
+func TestClearWaitCountsRejected(t *testing.T) {
+	p := NewWithNum(1, 10)
+	defer p.ShutdownNow()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := p.Run(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	for i := 0; i < 5; i++ {
+		if err := p.Run(func() {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the dispatcher holds one task waiting for the worker
+	for p.GetWaitNum() > 4 {
+		time.Sleep(time.Millisecond)
+	}
+	p.ClearWait()
+	if stats := p.Stats(); stats.Rejected != 4 {
+		t.Errorf("%d tasks rejected, want the 4 cleared ones", stats.Rejected)
+	}
+	close(release)
+}
+
+func TestReportStatsRejectsInvalidInterval(t *testing.T) {
+	p := New()
+	defer p.ShutdownNow()
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if stop, err := p.ReportStats(interval, func(stats Stats) {}); err == nil || stop != nil {
+			t.Errorf("ReportStats(%s) returned no error", interval)
+		}
+	}
+	reported := make(chan Stats, 1)
+	stop, err := p.ReportStats(time.Millisecond, func(stats Stats) {
+		select {
+		case reported <- stats:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Fatal("ReportStats didn't report")
+	}
+}
+
+func TestFuturePanicCountsAsFailed(t *testing.T) {
+	p := NewWithNum(1, 10)
+	p.SetPanicHandler(func(panicError *PanicError) {})
+	future := Submit(p, func() (int, error) {
+		panic("boom")
+	})
+	if _, err := future.Get(); err == nil {
+		t.Fatal("Get of a panicking task returned no error")
+	} else if _, ok := err.(*PanicError); !ok {
+		t.Fatalf("Get returned %v, want a *PanicError", err)
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := p.Stats(); stats.Completed != 1 || stats.Failed != 1 {
+		t.Errorf("stats %+v, want 1 completed and failed task", stats)
+	}
+}
+
+func TestCancelledFutureIsNotCompleted(t *testing.T) {
+	p := NewWithNum(1, 10)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := p.Run(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	var ran int32
+	future := Submit(p, func() (int, error) {
+		atomic.AddInt32(&ran, 1)
+		return 1, nil
+	})
+	if !future.Cancel() {
+		t.Fatal("Cancel of a waiting future returned false")
+	}
+	if !future.IsCancelled() {
+		t.Error("IsCancelled returned false after Cancel")
+	}
+	if _, err := future.Get(); err != ErrCancelled {
+		t.Errorf("Get returned %v, want ErrCancelled", err)
+	}
+	close(release)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Error("cancelled task ran")
+	}
+	if stats := p.Stats(); stats.Submitted != 2 || stats.Completed != 1 || stats.Rejected != 1 {
+		t.Errorf("stats %+v, want 2 submitted, 1 completed and 1 rejected task", stats)
+	}
+}
+
+func TestRunKeyedRacingShutdownNow(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		p := NewWithNum(1, 10)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		if err := p.RunKeyed("device", func() {
+			close(started)
+			<-release
+		}); err != nil {
+			t.Fatal(err)
+		}
+		<-started
+		futures := make(chan *Future[int], 100)
+		go func() {
+			defer close(futures)
+			for j := 0; j < 100; j++ {
+				futures <- SubmitKeyed(p, "device", func() (int, error) {
+					return 1, nil
+				})
+			}
+		}()
+		tasks := p.ShutdownNow()
+		close(release)
+		for _, task := range tasks {
+			task()
+		}
+		for future := range futures {
+			if _, err := future.GetWithTimeout(time.Second); err == ErrTimeout {
+				t.Fatal("future of a keyed task submitted during ShutdownNow never completed")
+			}
+		}
+	}
+}
+
+// waitFor waits until cond returns true.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFutureGet(t *testing.T) {
+	p := NewWithNum(2, 10)
+	defer p.Shutdown(context.Background())
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name  string
+		f     func() (int, error)
+		value int
+		err   error
+	}{
+		{"value", func() (int, error) { return 7, nil }, 7, nil},
+		{"error", func() (int, error) { return 0, errFailed }, 0, errFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := Submit(p, test.f).Get()
+			if value != test.value || err != test.err {
+				t.Errorf("Get = %d, %v, want %d, %v", value, err, test.value, test.err)
+			}
+		})
+	}
+}
+
+func TestFutureGetWithTimeout(t *testing.T) {
+	p := NewWithNum(1, 10)
+	defer p.Shutdown(context.Background())
+	release := make(chan struct{})
+	future := Submit(p, func() (string, error) {
+		<-release
+		return "done", nil
+	})
+	if _, err := future.GetWithTimeout(20 * time.Millisecond); err != ErrTimeout {
+		t.Fatalf("GetWithTimeout of a running task = %v, want ErrTimeout", err)
+	}
+	select {
+	case <-future.Done():
+		t.Fatal("Done is closed after a timeout")
+	default:
+	}
+	close(release)
+	if value, err := future.GetWithTimeout(time.Second); value != "done" || err != nil {
+		t.Errorf("GetWithTimeout = %q, %v, want done, nil", value, err)
+	}
+}
+
+func TestFutureCancelRunning(t *testing.T) {
+	p := NewWithNum(1, 10)
+	defer p.Shutdown(context.Background())
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	future := SubmitContext(p, func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+		return 1, nil
+	})
+	<-started
+	if !future.Cancel() {
+		t.Fatal("Cancel of a running future returned false")
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("the context of a cancelled future was not cancelled")
+	}
+	if value, err := future.Get(); value != 0 || err != ErrCancelled {
+		t.Errorf("Get = %d, %v, want 0, ErrCancelled", value, err)
+	}
+	if future.Cancel() {
+		t.Error("second Cancel returned true")
+	}
+	done := Submit(p, func() (int, error) { return 1, nil })
+	done.Get()
+	if done.Cancel() || done.IsCancelled() {
+		t.Error("Cancel of a completed future changed it")
+	}
+}
+
+func TestFuturePanic(t *testing.T) {
+	p := NewWithNum(1, 10)
+	defer p.Shutdown(context.Background())
+	var handled int32
+	p.SetPanicHandler(func(panicError *PanicError) {
+		atomic.AddInt32(&handled, 1)
+	})
+	_, err := Submit(p, func() (int, error) {
+		panic("boom")
+	}).Get()
+	panicError, ok := err.(*PanicError)
+	if !ok || panicError.Value != "boom" {
+		t.Fatalf("Get error = %v, want a *PanicError holding boom", err)
+	}
+	if atomic.LoadInt32(&handled) != 1 {
+		t.Error("the panic handler was not called once")
+	}
+	if value, err := Submit(p, func() (int, error) { return 2, nil }).Get(); value != 2 || err != nil {
+		t.Errorf("the pool didn't run a task after a panic: %d, %v", value, err)
+	}
+}
+
+func TestSubmitErrors(t *testing.T) {
+	p := NewWithNum(1, 10)
+	if _, err := SubmitWithPriority(p, Priority(10), func() (int, error) { return 1, nil }).Get(); err == nil {
+		t.Error("SubmitWithPriority with an invalid priority returned no error")
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Submit(p, func() (int, error) { return 1, nil }).Get(); err != ErrShutdown {
+		t.Errorf("Submit after Shutdown = %v, want ErrShutdown", err)
+	}
+}
+
+func TestInvokeAll(t *testing.T) {
+	p := NewWithNum(4, 10)
+	defer p.Shutdown(context.Background())
+	errOdd := errors.New("odd")
+	tasks := make([]func() (int, error), 6)
+	for i := range tasks {
+		i := i
+		tasks[i] = func() (int, error) {
+			time.Sleep(time.Duration(6-i) * time.Millisecond)
+			if i%2 == 1 {
+				return 0, errOdd
+			}
+			return i * 10, nil
+		}
+	}
+	values, errs := InvokeAll(p, tasks...)
+	for i := range tasks {
+		wantValue, wantErr := i*10, error(nil)
+		if i%2 == 1 {
+			wantValue, wantErr = 0, errOdd
+		}
+		if values[i] != wantValue || errs[i] != wantErr {
+			t.Errorf("task %d = %d, %v, want %d, %v", i, values[i], errs[i], wantValue, wantErr)
+		}
+	}
+}
+
+// blockTasks runs n tasks blocking until release is closed.
+func blockTasks(t *testing.T, p *Zpool, n int, release chan struct{}) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := p.Run(func() {
+			<-release
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSetMaxWorkers(t *testing.T) {
+	p := NewWithNum(1, 10)
+	defer p.Shutdown(context.Background())
+	release := make(chan struct{})
+	blockTasks(t, p, 4, release)
+	waitFor(t, "1 running task", func() bool {
+		return p.GetCurNum() == 1 && p.GetWaitNum()+p.GetCurNum() >= 3
+	})
+
+	p.SetMaxWorkers(4)
+	waitFor(t, "4 running tasks after growing", func() bool {
+		return p.GetCurNum() == 4 && p.GetWorkerNum() == 4
+	})
+
+	p.SetMaxWorkers(2)
+	close(release)
+	waitFor(t, "workers to shrink to 2", func() bool {
+		return p.GetCurNum() == 0 && p.GetWorkerNum() <= 2
+	})
+
+	release = make(chan struct{})
+	blockTasks(t, p, 4, release)
+	waitFor(t, "2 running tasks after shrinking", func() bool {
+		return p.GetCurNum() == 2
+	})
+	time.Sleep(20 * time.Millisecond)
+	if num := p.GetCurNum(); num != 2 {
+		t.Errorf("%d tasks running, want at most 2", num)
+	}
+	close(release)
+
+	p.SetMaxWorkers(0)
+	if num := p.GetMaxWorkers(); num != 1 {
+		t.Errorf("GetMaxWorkers after SetMaxWorkers(0) = %d, want 1", num)
+	}
+}
+
+func TestIdleWorkersExit(t *testing.T) {
+	p := NewWithWorkers(1, 4, 10, 20*time.Millisecond)
+	defer p.Shutdown(context.Background())
+	release := make(chan struct{})
+	blockTasks(t, p, 4, release)
+	waitFor(t, "4 workers", func() bool {
+		return p.GetWorkerNum() == 4
+	})
+	close(release)
+	waitFor(t, "idle workers above the core number to exit", func() bool {
+		return p.GetWorkerNum() == 1
+	})
+	time.Sleep(60 * time.Millisecond)
+	if num := p.GetWorkerNum(); num != 1 {
+		t.Errorf("%d workers, want the core worker to stay", num)
+	}
+
+	p.SetCoreWorkers(0)
+	waitFor(t, "the former core worker to exit", func() bool {
+		return p.GetWorkerNum() == 0
+	})
+	if value, err := Submit(p, func() (int, error) { return 1, nil }).Get(); value != 1 || err != nil {
+		t.Errorf("Submit without workers = %d, %v, want 1, nil", value, err)
+	}
+
+	p.SetCoreWorkers(-1)
+	if num := p.GetCoreWorkers(); num != 0 {
+		t.Errorf("GetCoreWorkers after SetCoreWorkers(-1) = %d, want 0", num)
+	}
+}
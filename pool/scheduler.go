@@ -0,0 +1,305 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler runs delayed and recurring tasks in a Zpool, so they share its workers, panic
+// handling and stats.
+//
+// sample
+//
+//	scheduler := pool.NewScheduler(pool.New())
+//	task, err := scheduler.ScheduleWithFixedDelay(0, time.Minute, pullRepo, pool.WithJitter(5*time.Second))
+//	_, err = scheduler.ScheduleCron("0 */10 * * * *", checkSignatures)
+//	...
+//	task.Cancel()
+type Scheduler struct {
+	pool  *Zpool
+	lock  sync.Mutex
+	tasks map[*ScheduledTask]struct{}
+}
+
+func NewScheduler(p *Zpool) *Scheduler {
+	return &Scheduler{pool: p, tasks: make(map[*ScheduledTask]struct{})}
+}
+
+// ScheduleOption changes how a task is scheduled.
+type ScheduleOption func(task *ScheduledTask)
+
+// WithJitter delays every run by a random duration below jitter, so tasks scheduled at the
+// same time don't all start at once.
+func WithJitter(jitter time.Duration) ScheduleOption {
+	return func(task *ScheduledTask) {
+		task.jitter = jitter
+	}
+}
+
+// AllowOverlap lets a run start while the previous run of the task is still going, by
+// default such a run is skipped.
+func AllowOverlap() ScheduleOption {
+	return func(task *ScheduledTask) {
+		task.allowOverlap = true
+	}
+}
+
+// ScheduledTask is the handle of a scheduled task.
+type ScheduledTask struct {
+	scheduler    *Scheduler
+	f            func()
+	jitter       time.Duration
+	allowOverlap bool
+
+	cancelCh   chan struct{}
+	cancelOnce sync.Once
+	done       chan struct{}
+	running    int32
+	runs       int64
+	skipped    int64
+	lock       sync.Mutex
+	nextRun    time.Time
+}
+
+// Cancel stops the task, a run already started goes on.
+func (task *ScheduledTask) Cancel() {
+	task.cancelOnce.Do(func() {
+		close(task.cancelCh)
+	})
+}
+
+// Done is closed when no more runs of the task will start, after Cancel or after the
+// single run of Schedule.
+func (task *ScheduledTask) Done() <-chan struct{} {
+	return task.done
+}
+
+// NextRun returns when the task runs next without jitter, the zero time if it won't.
+func (task *ScheduledTask) NextRun() time.Time {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+	return task.nextRun
+}
+
+// Runs returns how many runs were submitted to the pool.
+func (task *ScheduledTask) Runs() int64 {
+	return atomic.LoadInt64(&task.runs)
+}
+
+// Skipped returns how many runs were skipped because the previous run was still going.
+func (task *ScheduledTask) Skipped() int64 {
+	return atomic.LoadInt64(&task.skipped)
+}
+
+// Schedule runs f once after delay, it returns an error if delay or the jitter is negative.
+func (scheduler *Scheduler) Schedule(delay time.Duration, f func(), opts ...ScheduleOption) (*ScheduledTask, error) {
+	task, err := scheduler.newTask(delay, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	first := time.Now().Add(delay)
+	scheduler.start(task, func(task *ScheduledTask) {
+		if task.wait(first) {
+			task.fire()
+		}
+	})
+	return task, nil
+}
+
+// ScheduleAtFixedRate runs f after initialDelay and then every period counted from the
+// first run, runs due while the previous one is still going are skipped. It returns an
+// error if period is not positive or initialDelay or the jitter is negative.
+func (scheduler *Scheduler) ScheduleAtFixedRate(initialDelay time.Duration, period time.Duration, f func(), opts ...ScheduleOption) (*ScheduledTask, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("schedule period %s is not positive", period)
+	}
+	task, err := scheduler.newTask(initialDelay, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	next := time.Now().Add(initialDelay)
+	scheduler.start(task, func(task *ScheduledTask) {
+		for task.wait(next) {
+			task.fire()
+			now := time.Now()
+			for !next.After(now) {
+				next = next.Add(period)
+			}
+		}
+	})
+	return task, nil
+}
+
+// ScheduleWithFixedDelay runs f after initialDelay and then delay after each run finished.
+// It returns an error if initialDelay, delay or the jitter is negative.
+func (scheduler *Scheduler) ScheduleWithFixedDelay(initialDelay time.Duration, delay time.Duration, f func(), opts ...ScheduleOption) (*ScheduledTask, error) {
+	if delay < 0 {
+		return nil, fmt.Errorf("schedule fixed delay %s is negative", delay)
+	}
+	task, err := scheduler.newTask(initialDelay, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	next := time.Now().Add(initialDelay)
+	scheduler.start(task, func(task *ScheduledTask) {
+		for task.wait(next) {
+			finished := task.fire()
+			select {
+			case <-finished:
+			case <-task.cancelCh:
+				return
+			}
+			next = time.Now().Add(delay)
+		}
+	})
+	return task, nil
+}
+
+// ScheduleCron runs f at the times matching the cron expression expr, see ParseCron.
+func (scheduler *Scheduler) ScheduleCron(expr string, f func(), opts ...ScheduleOption) (*ScheduledTask, error) {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	return scheduler.ScheduleCronSchedule(schedule, f, opts...)
+}
+
+// ScheduleCronSchedule runs f at the times matching schedule in the local time zone, it
+// returns an error if schedule is nil or the jitter is negative.
+func (scheduler *Scheduler) ScheduleCronSchedule(schedule *CronSchedule, f func(), opts ...ScheduleOption) (*ScheduledTask, error) {
+	if schedule == nil {
+		return nil, errors.New("cron schedule is nil")
+	}
+	task, err := scheduler.newTask(0, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	scheduler.start(task, func(task *ScheduledTask) {
+		next := schedule.Next(time.Now())
+		for !next.IsZero() && task.wait(next) {
+			task.fire()
+			next = schedule.Next(next)
+			if now := time.Now(); next.Before(now) {
+				next = schedule.Next(now)
+			}
+		}
+	})
+	return task, nil
+}
+
+// Stop cancels all tasks of the scheduler.
+func (scheduler *Scheduler) Stop() {
+	scheduler.lock.Lock()
+	tasks := make([]*ScheduledTask, 0, len(scheduler.tasks))
+	for task := range scheduler.tasks {
+		tasks = append(tasks, task)
+	}
+	scheduler.lock.Unlock()
+	for _, task := range tasks {
+		task.Cancel()
+	}
+}
+
+// newTask builds a task first run after delay, it returns an error if delay or the jitter
+// is negative.
+func (scheduler *Scheduler) newTask(delay time.Duration, f func(), opts []ScheduleOption) (*ScheduledTask, error) {
+	if delay < 0 {
+		return nil, fmt.Errorf("schedule delay %s is negative", delay)
+	}
+	task := &ScheduledTask{
+		scheduler: scheduler,
+		f:         f,
+		cancelCh:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(task)
+	}
+	if task.jitter < 0 {
+		return nil, fmt.Errorf("schedule jitter %s is negative", task.jitter)
+	}
+	return task, nil
+}
+
+func (scheduler *Scheduler) start(task *ScheduledTask, loop func(task *ScheduledTask)) *ScheduledTask {
+	scheduler.lock.Lock()
+	scheduler.tasks[task] = struct{}{}
+	scheduler.lock.Unlock()
+	go func() {
+		defer func() {
+			task.setNextRun(time.Time{})
+			scheduler.lock.Lock()
+			delete(scheduler.tasks, task)
+			scheduler.lock.Unlock()
+			close(task.done)
+		}()
+		loop(task)
+	}()
+	return task
+}
+
+func (task *ScheduledTask) setNextRun(next time.Time) {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+	task.nextRun = next
+}
+
+// wait sleeps until next plus jitter, it returns false if the task was cancelled or the
+// pool was shut down.
+func (task *ScheduledTask) wait(next time.Time) bool {
+	task.setNextRun(next)
+	delay := time.Until(next)
+	if task.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(task.jitter)))
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-task.cancelCh:
+		return false
+	case <-task.scheduler.pool.closing:
+		return false
+	}
+}
+
+// fire submits a run to the pool unless the previous run is still going and overlap is
+// not allowed. The returned channel is closed when the run finished or was dropped.
+func (task *ScheduledTask) fire() <-chan struct{} {
+	finished := make(chan struct{})
+	if task.allowOverlap {
+		atomic.AddInt32(&task.running, 1)
+	} else if !atomic.CompareAndSwapInt32(&task.running, 0, 1) {
+		atomic.AddInt64(&task.skipped, 1)
+		close(finished)
+		return finished
+	}
+	var once sync.Once
+	finish := func() {
+		once.Do(func() {
+			atomic.AddInt32(&task.running, -1)
+			close(finished)
+		})
+	}
+	zf, _ := newZfunc(func() {
+		defer finish()
+		task.f()
+	}, nil)
+	zf.onDrop = func(err error) {
+		finish()
+	}
+	if err := task.scheduler.pool.submit(zf); err != nil {
+		finish()
+		if err == ErrShutdown {
+			task.Cancel()
+		}
+		return finished
+	}
+	atomic.AddInt64(&task.runs, 1)
+	return finished
+}
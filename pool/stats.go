@@ -0,0 +1,221 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	Log "github.com/wellmoon/go/logger"
+)
+
+// histogramBounds are the upper bounds of the histogram buckets, the last bucket has no bound.
+var histogramBounds = []time.Duration{
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second,
+	10 * time.Second, 30 * time.Second, time.Minute,
+}
+
+// Bucket counts the durations up to UpperBound which didn't fit an earlier bucket, the
+// UpperBound of the last bucket is 0 and means no bound.
+type Bucket struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
+// Histogram is a snapshot of the durations recorded by a pool.
+type Histogram struct {
+	Count   int64
+	Sum     time.Duration
+	Max     time.Duration
+	Buckets []Bucket
+}
+
+func (histogram Histogram) Mean() time.Duration {
+	if histogram.Count == 0 {
+		return 0
+	}
+	return histogram.Sum / time.Duration(histogram.Count)
+}
+
+// Percentile returns the upper bound of the bucket holding the q quantile, 0 < q <= 1, so
+// the result is an upper estimate. It is never above Max.
+func (histogram Histogram) Percentile(q float64) time.Duration {
+	if histogram.Count == 0 {
+		return 0
+	}
+	rank := int64(q*float64(histogram.Count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, bucket := range histogram.Buckets {
+		seen += bucket.Count
+		if seen >= rank {
+			if bucket.UpperBound == 0 || bucket.UpperBound > histogram.Max {
+				return histogram.Max
+			}
+			return bucket.UpperBound
+		}
+	}
+	return histogram.Max
+}
+
+type histogram struct {
+	lock   sync.Mutex
+	count  int64
+	sum    time.Duration
+	max    time.Duration
+	counts []int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int64, len(histogramBounds)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	idx := len(histogramBounds)
+	for i, bound := range histogramBounds {
+		if d <= bound {
+			idx = i
+			break
+		}
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+	h.counts[idx]++
+}
+
+func (h *histogram) snapshot() Histogram {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	res := Histogram{Count: h.count, Sum: h.sum, Max: h.max, Buckets: make([]Bucket, len(h.counts))}
+	for i, count := range h.counts {
+		res.Buckets[i].Count = count
+		if i < len(histogramBounds) {
+			res.Buckets[i].UpperBound = histogramBounds[i]
+		}
+	}
+	return res
+}
+
+type poolStats struct {
+	submitted int64
+	completed int64
+	failed    int64
+	rejected  int64
+	since     time.Time
+	waitTime  *histogram
+	execTime  *histogram
+}
+
+func newPoolStats() *poolStats {
+	return &poolStats{since: time.Now(), waitTime: newHistogram(), execTime: newHistogram()}
+}
+
+// Stats is a snapshot of the counters of a pool. Submitted counts the accepted tasks,
+// Completed the tasks which finished running, Failed the completed tasks which panicked or
+// returned a non nil error as last result, and Rejected the tasks refused or dropped
+// because the queue was full, the pool was shut down, ClearWait was called or their future
+// was cancelled before they started. WaitTime is the time tasks spent waiting before they
+// started and ExecTime the time they ran.
+type Stats struct {
+	Submitted int64
+	Completed int64
+	Failed    int64
+	Rejected  int64
+	Running   int
+	Waiting   int
+	Workers   int
+	Since     time.Time
+	WaitTime  Histogram
+	ExecTime  Histogram
+}
+
+func (p *Zpool) Stats() Stats {
+	return Stats{
+		Submitted: atomic.LoadInt64(&p.stats.submitted),
+		Completed: atomic.LoadInt64(&p.stats.completed),
+		Failed:    atomic.LoadInt64(&p.stats.failed),
+		Rejected:  atomic.LoadInt64(&p.stats.rejected),
+		Running:   p.GetCurNum(),
+		Waiting:   p.GetWaitNum(),
+		Workers:   p.GetWorkerNum(),
+		Since:     p.stats.since,
+		WaitTime:  p.stats.waitTime.snapshot(),
+		ExecTime:  p.stats.execTime.snapshot(),
+	}
+}
+
+func (p *Zpool) countSubmitted(zf *zfunc) {
+	if !zf.internal {
+		atomic.AddInt64(&p.stats.submitted, 1)
+	}
+}
+
+func (p *Zpool) countRejected(zf *zfunc) {
+	if !zf.internal {
+		atomic.AddInt64(&p.stats.rejected, 1)
+	}
+}
+
+// reject counts zf as rejected and drops it with err.
+func (p *Zpool) reject(zf *zfunc, err error) {
+	p.countRejected(zf)
+	zf.drop(err)
+}
+
+// ReportStats calls report with the stats of the pool every interval until the returned
+// func is called or the pool is shut down. A nil report logs the stats with logger.Debug.
+// It returns an error if interval is not positive.
+func (p *Zpool) ReportStats(interval time.Duration, report func(stats Stats)) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("stats report interval %s is not positive", interval)
+	}
+	if report == nil {
+		report = logStats(interval)
+	}
+	stopCh := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				report(p.Stats())
+			case <-stopCh:
+				return
+			case <-p.closing:
+				return
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() {
+			close(stopCh)
+		})
+	}, nil
+}
+
+// logStats returns a report func logging the stats and the tasks completed per second
+// since the previous report.
+func logStats(interval time.Duration) func(stats Stats) {
+	var last int64
+	return func(stats Stats) {
+		rate := float64(stats.Completed-last) / interval.Seconds()
+		last = stats.Completed
+		Log.Debug("pool submitted {} completed {} failed {} rejected {} running {} waiting {} workers {} rate {}/s wait p50 {} p99 {} exec p50 {} p99 {}",
+			stats.Submitted, stats.Completed, stats.Failed, stats.Rejected, stats.Running, stats.Waiting, stats.Workers,
+			int64(rate), stats.WaitTime.Percentile(0.5), stats.WaitTime.Percentile(0.99),
+			stats.ExecTime.Percentile(0.5), stats.ExecTime.Percentile(0.99))
+	}
+}
@@ -0,0 +1,193 @@
+package pool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SetMaxWorkers changes the maximum number of workers, n below 1 is taken as 1. When it
+// shrinks, extra workers exit after their current task.
+func (p *Zpool) SetMaxWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreInt32(&p.maxWorkers, int32(n))
+	p.wake()
+}
+
+// SetCoreWorkers changes the number of workers kept alive when idle.
+func (p *Zpool) SetCoreWorkers(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&p.coreWorkers, int32(n))
+}
+
+// SetIdleTimeout changes how long a worker above the core number waits for a task before
+// it exits, it applies from the next wait.
+func (p *Zpool) SetIdleTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+	atomic.StoreInt64(&p.idleTimeout, int64(timeout))
+}
+
+func (p *Zpool) GetMaxWorkers() int {
+	return int(atomic.LoadInt32(&p.maxWorkers))
+}
+
+func (p *Zpool) GetCoreWorkers() int {
+	return int(atomic.LoadInt32(&p.coreWorkers))
+}
+
+// GetWorkerNum returns the number of live workers, busy or idle.
+func (p *Zpool) GetWorkerNum() int {
+	return int(atomic.LoadInt32(&p.workerNum))
+}
+
+func (p *Zpool) wake() {
+	select {
+	case p.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// start runs the dispatcher, it passes every task to an idle worker or a new one and waits
+// while all workers are busy. It exits when the wait queues are closed and empty, and then
+// closes taskCh so the workers exit too.
+func (p *Zpool) start() {
+	go func() {
+		defer close(p.exited)
+		defer close(p.taskCh)
+		for {
+			zf, ok := p.take()
+			if !ok {
+				return
+			}
+			if p.isStopped() {
+				p.drop(zf)
+				continue
+			}
+			p.dispatch(zf)
+		}
+	}()
+}
+
+// take returns the next task of the highest priority, waiting if there is none. It
+// returns false once the wait queues are closed and empty.
+func (p *Zpool) take() (*zfunc, bool) {
+	for {
+		closed := 0
+		for priority := priorityNum - 1; priority >= PriorityLow; priority-- {
+			select {
+			case zf, ok := <-p.waitChs[priority]:
+				if ok {
+					return zf, true
+				}
+				closed++
+			default:
+			}
+		}
+		if closed == len(p.waitChs) {
+			return nil, false
+		}
+		var zf *zfunc
+		var ok bool
+		select {
+		case zf, ok = <-p.waitChs[PriorityHigh]:
+		case zf, ok = <-p.waitChs[PriorityNormal]:
+		case zf, ok = <-p.waitChs[PriorityLow]:
+		}
+		if ok {
+			return zf, true
+		}
+	}
+}
+
+func (p *Zpool) dispatch(zf *zfunc) {
+	for {
+		select {
+		case p.taskCh <- zf:
+			return
+		default:
+		}
+		if p.addWorker() {
+			p.workers.Add(1)
+			go p.worker(zf)
+			return
+		}
+		select {
+		case p.taskCh <- zf:
+			return
+		case <-p.wakeCh:
+		case <-p.stopCh:
+			p.drop(zf)
+			return
+		}
+	}
+}
+
+func (p *Zpool) addWorker() bool {
+	for {
+		num := atomic.LoadInt32(&p.workerNum)
+		if num >= atomic.LoadInt32(&p.maxWorkers) {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&p.workerNum, num, num+1) {
+			return true
+		}
+	}
+}
+
+// removeWorker lets a worker exit if there are more than limit workers.
+func (p *Zpool) removeWorker(limit int32) bool {
+	for {
+		num := atomic.LoadInt32(&p.workerNum)
+		if num <= limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&p.workerNum, num, num-1) {
+			p.wake()
+			return true
+		}
+	}
+}
+
+func (p *Zpool) worker(zf *zfunc) {
+	defer p.workers.Done()
+	for zf != nil {
+		atomic.AddInt32(&p.busyNum, 1)
+		zf.invoke(p)
+		atomic.AddInt32(&p.busyNum, -1)
+		if p.removeWorker(atomic.LoadInt32(&p.maxWorkers)) {
+			return
+		}
+		zf = p.nextTask()
+	}
+}
+
+// nextTask waits for the next task of an idle worker, it returns nil when the worker
+// should exit because the pool is shut down or it was idle too long.
+func (p *Zpool) nextTask() *zfunc {
+	timer := time.NewTimer(time.Duration(atomic.LoadInt64(&p.idleTimeout)))
+	defer timer.Stop()
+	for {
+		select {
+		case zf, ok := <-p.taskCh:
+			if !ok {
+				atomic.AddInt32(&p.workerNum, -1)
+				return nil
+			}
+			return zf
+		case <-timer.C:
+			limit := atomic.LoadInt32(&p.coreWorkers)
+			if max := atomic.LoadInt32(&p.maxWorkers); max < limit {
+				limit = max
+			}
+			if p.removeWorker(limit) {
+				return nil
+			}
+			timer.Reset(time.Duration(atomic.LoadInt64(&p.idleTimeout)))
+		}
+	}
+}
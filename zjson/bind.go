@@ -0,0 +1,543 @@
+package zjson
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	jsonObjectType       = reflect.TypeOf((*JSONObject)(nil))
+	jsonMarshalerType    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType  = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	errBindTargetPointer = errors.New("bind target must be a non-nil pointer")
+)
+
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	asString  bool
+}
+
+// structFields returns the json fields of t like encoding/json, fields of embedded
+// structs are flattened and fields declared directly take precedence over them.
+func structFields(t reflect.Type) []structField {
+	fields := make([]structField, 0)
+	embedded := make([]structField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, inner := range structFields(ft) {
+				inner.index = append([]int{i}, inner.index...)
+				embedded = append(embedded, inner)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			asString:  strings.Contains(","+opts+",", ",string,"),
+		})
+	}
+	for _, inner := range embedded {
+		exists := false
+		for _, f := range fields {
+			if f.name == inner.name {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			fields = append(fields, inner)
+		}
+	}
+	return fields
+}
+
+// FromStruct converts a struct, a pointer to struct or a map into a JSONObject using the
+// json tags of the fields, without a marshal and unmarshal round trip. Nested structs and
+// maps become map[string]interface{}, slices become []interface{} and numbers keep their
+// Go type. Values implementing json.Marshaler, like time.Time, are converted through json.
+// A value referencing itself through pointers, maps or slices returns an error.
+func FromStruct(v interface{}) (*JSONObject, error) {
+	value, err := toJSONValue(reflect.ValueOf(v), false, make(map[visitKey]bool))
+	if err != nil {
+		return nil, err
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		jsonObject := NewObject()
+		jsonObject.ItemMap = value
+		return jsonObject, nil
+	case *JSONObject:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("FromStruct can't convert %T to JSONObject", v)
+	}
+}
+
+// visitKey identifies a pointer, map or slice being converted, the type and length keep a
+// struct apart from its first field and a slice apart from a shorter slice of it.
+type visitKey struct {
+	ptr    uintptr
+	typ    reflect.Type
+	length int
+}
+
+// enter marks rv as being converted and returns the func to call when done with it, or an
+// error if rv is already being converted higher up, which would recurse forever.
+func enter(rv reflect.Value, visiting map[visitKey]bool) (func(), error) {
+	key := visitKey{rv.Pointer(), rv.Type(), 0}
+	if rv.Kind() == reflect.Slice {
+		key.length = rv.Len()
+	}
+	if visiting[key] {
+		return nil, fmt.Errorf("cycle through %s", rv.Type())
+	}
+	visiting[key] = true
+	return func() {
+		delete(visiting, key)
+	}, nil
+}
+
+func toJSONValue(rv reflect.Value, asString bool, visiting map[visitKey]bool) (interface{}, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.Type() == jsonObjectType {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return rv.Interface(), nil
+	}
+	if rv.Kind() != reflect.Ptr && rv.Kind() != reflect.Interface && rv.Type().Implements(jsonMarshalerType) ||
+		rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Type().Implements(jsonMarshalerType) {
+		b, err := json.Marshal(rv.Interface())
+		if err != nil {
+			return nil, err
+		}
+		var res interface{}
+		err = json.Unmarshal(b, &res)
+		return res, err
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if !rv.IsNil() {
+			leave, err := enter(rv, visiting)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return toJSONValue(rv.Elem(), asString, visiting)
+	case reflect.Struct:
+		res := make(map[string]interface{})
+		for _, f := range structFields(rv.Type()) {
+			fv, ok := fieldByIndex(rv, f.index)
+			if !ok || f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			val, err := toJSONValue(fv, f.asString, visiting)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.name, err)
+			}
+			res[f.name] = val
+		}
+		return res, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		res := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			val, err := toJSONValue(iter.Value(), false, visiting)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			res[key] = val
+		}
+		return res, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte is base64 encoded like encoding/json
+			b, err := json.Marshal(rv.Interface())
+			if err != nil {
+				return nil, err
+			}
+			var res string
+			err = json.Unmarshal(b, &res)
+			return res, err
+		}
+		res := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			val, err := toJSONValue(rv.Index(i), false, visiting)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+			res[i] = val
+		}
+		return res, nil
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return nil, fmt.Errorf("unsupported type %s", rv.Type())
+	}
+	if asString {
+		return ToStr(rv.Interface()), nil
+	}
+	return rv.Interface(), nil
+}
+
+// fieldByIndex returns the field, ok is false when it is inside a nil embedded pointer.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, idx := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(idx)
+	}
+	return rv, true
+}
+
+// Bind sets the fields of target, a pointer to a struct or map, from the object using the
+// json tags of the fields. Numeric strings, numbers and bools are converted leniently and
+// unknown keys are ignored, the error lists every value that couldn't be converted.
+func (jsonObject *JSONObject) Bind(target interface{}) error {
+	return jsonObject.bind(target, false)
+}
+
+// BindStrict is like Bind but also reports unknown keys and values whose json type
+// doesn't match the field, such as a string for an int field.
+func (jsonObject *JSONObject) BindStrict(target interface{}) error {
+	return jsonObject.bind(target, true)
+}
+
+type binder struct {
+	strict bool
+	errs   []string
+}
+
+func (b *binder) fail(path string, format string, args ...interface{}) {
+	if path == "" {
+		path = "$"
+	}
+	b.errs = append(b.errs, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (jsonObject *JSONObject) bind(target interface{}, strict bool) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errBindTargetPointer
+	}
+	b := &binder{strict: strict}
+	b.assign(rv.Elem(), jsonObject, "")
+	if len(b.errs) > 0 {
+		return errors.New("bind error: " + strings.Join(b.errs, "; "))
+	}
+	return nil
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// objectItems returns a shallow copy of the members of a json object value.
+func objectItems(src interface{}) (map[string]interface{}, bool) {
+	switch value := src.(type) {
+	case *JSONObject:
+		value.lock.RLock()
+		defer value.lock.RUnlock()
+		res := make(map[string]interface{}, len(value.ItemMap))
+		for k, v := range value.ItemMap {
+			res[k] = v
+		}
+		return res, true
+	case map[string]interface{}:
+		return value, true
+	}
+	return nil, false
+}
+
+func (b *binder) assign(dst reflect.Value, src interface{}, path string) {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return
+	}
+	if dst.Kind() != reflect.Ptr && dst.Kind() != reflect.Interface && dst.CanAddr() {
+		addr := dst.Addr().Type()
+		if addr.Implements(jsonUnmarshalerType) || addr.Implements(textUnmarshalerType) && dst.Kind() != reflect.String {
+			b.assignByJSON(dst, src, path)
+			return
+		}
+	}
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.Type() == jsonObjectType {
+			obj, err := ParseJSONObject(src)
+			if err != nil {
+				b.fail(path, "%v", err)
+				return
+			}
+			dst.Set(reflect.ValueOf(obj))
+			return
+		}
+		elem := reflect.New(dst.Type().Elem())
+		b.assign(elem.Elem(), src, path)
+		dst.Set(elem)
+	case reflect.Interface:
+		val := reflect.ValueOf(src)
+		if !val.Type().AssignableTo(dst.Type()) {
+			b.fail(path, "%T is not assignable to %s", src, dst.Type())
+			return
+		}
+		dst.Set(val)
+	case reflect.Bool:
+		val, ok := src.(bool)
+		if !ok {
+			var err error
+			val, err = ToBool(src)
+			if b.strict || err != nil {
+				b.fail(path, "can't convert %s to bool", typeOf(src))
+				return
+			}
+		}
+		dst.SetBool(val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !b.checkNumber(src, path) {
+			return
+		}
+		val, err := ToInt64(src)
+		if err != nil {
+			b.fail(path, "%v", err)
+			return
+		}
+		if dst.OverflowInt(val) {
+			b.fail(path, "%v overflows %s", val, dst.Type())
+			return
+		}
+		dst.SetInt(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if !b.checkNumber(src, path) {
+			return
+		}
+		val, err := ToInt64(src)
+		if err != nil {
+			b.fail(path, "%v", err)
+			return
+		}
+		if val < 0 || dst.OverflowUint(uint64(val)) {
+			b.fail(path, "%v overflows %s", val, dst.Type())
+			return
+		}
+		dst.SetUint(uint64(val))
+	case reflect.Float32, reflect.Float64:
+		if !b.checkNumber(src, path) {
+			return
+		}
+		val, err := ToFloat64(src)
+		if err != nil {
+			b.fail(path, "%v", err)
+			return
+		}
+		if dst.OverflowFloat(val) {
+			b.fail(path, "%v overflows %s", val, dst.Type())
+			return
+		}
+		dst.SetFloat(val)
+	case reflect.String:
+		val, ok := src.(string)
+		if !ok {
+			if b.strict || !isScalar(src) {
+				b.fail(path, "can't convert %s to string", typeOf(src))
+				return
+			}
+			val = ToStr(src)
+		}
+		dst.SetString(val)
+	case reflect.Struct:
+		items, ok := objectItems(src)
+		if !ok {
+			b.fail(path, "can't convert %s to %s", typeOf(src), dst.Type())
+			return
+		}
+		b.assignStruct(dst, items, path)
+	case reflect.Map:
+		items, ok := objectItems(src)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			b.fail(path, "can't convert %s to %s", typeOf(src), dst.Type())
+			return
+		}
+		res := reflect.MakeMapWithSize(dst.Type(), len(items))
+		for key, val := range items {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			b.assign(elem, val, joinPath(path, key))
+			res.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), elem)
+		}
+		dst.Set(res)
+	case reflect.Slice, reflect.Array:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			if _, ok := src.(string); ok {
+				b.assignByJSON(dst, src, path)
+				return
+			}
+		}
+		arr, ok := src.([]interface{})
+		if !ok {
+			if getter, gok := src.(arrayGetter); gok {
+				arr, ok = getter.GetArray(), true
+			}
+		}
+		if !ok {
+			b.fail(path, "can't convert %s to %s", typeOf(src), dst.Type())
+			return
+		}
+		if dst.Kind() == reflect.Slice {
+			dst.Set(reflect.MakeSlice(dst.Type(), len(arr), len(arr)))
+		} else if len(arr) > dst.Len() && b.strict {
+			b.fail(path, "array has %d elements, %s holds %d", len(arr), dst.Type(), dst.Len())
+		}
+		for i := 0; i < len(arr) && i < dst.Len(); i++ {
+			b.assign(dst.Index(i), arr[i], path+"["+strconv.Itoa(i)+"]")
+		}
+	default:
+		b.fail(path, "unsupported type %s", dst.Type())
+	}
+}
+
+func (b *binder) assignStruct(dst reflect.Value, items map[string]interface{}, path string) {
+	fields := structFields(dst.Type())
+	used := make(map[string]bool, len(items))
+	for _, f := range fields {
+		key := f.name
+		val, ok := items[key]
+		if !ok {
+			// encoding/json matches keys case insensitively
+			for k, v := range items {
+				if !used[k] && strings.EqualFold(k, f.name) {
+					key, val, ok = k, v, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		used[key] = true
+		fv := dst
+		for i, idx := range f.index {
+			if i > 0 && fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(idx)
+		}
+		if f.asString {
+			if s, sok := val.(string); sok {
+				val = s
+				if fv.Kind() != reflect.String {
+					var parsed interface{}
+					if err := json.Unmarshal([]byte(s), &parsed); err == nil {
+						val = parsed
+					}
+				}
+			}
+		}
+		b.assign(fv, val, joinPath(path, key))
+	}
+	if b.strict {
+		for key := range items {
+			if !used[key] {
+				b.fail(joinPath(path, key), "unknown field")
+			}
+		}
+	}
+}
+
+// checkNumber reports a non numeric value in strict mode.
+func (b *binder) checkNumber(src interface{}, path string) bool {
+	if !b.strict {
+		return true
+	}
+	switch src.(type) {
+	case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		return true
+	}
+	b.fail(path, "can't convert %s to number", typeOf(src))
+	return false
+}
+
+func (b *binder) assignByJSON(dst reflect.Value, src interface{}, path string) {
+	data, err := json.Marshal(src)
+	if err != nil {
+		b.fail(path, "%v", err)
+		return
+	}
+	err = json.Unmarshal(data, dst.Addr().Interface())
+	if err != nil {
+		b.fail(path, "%v", err)
+	}
+}
+
+func isScalar(src interface{}) bool {
+	switch src.(type) {
+	case string, bool, float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		return true
+	}
+	return false
+}
+
+func typeOf(src interface{}) string {
+	switch src.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case *JSONObject, map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if isScalar(src) {
+		return "number"
+	}
+	return fmt.Sprintf("%T", src)
+}
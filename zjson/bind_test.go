@@ -0,0 +1,269 @@
+package zjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type bindBase struct {
+	ID      int64 `json:"id"`
+	Version int   `json:"version"`
+}
+
+type bindUser struct {
+	bindBase
+	Name     string            `json:"name"`
+	Age      int               `json:"age"`
+	Score    float64           `json:"score"`
+	Active   bool              `json:"active"`
+	Count    int               `json:"count,string"`
+	Tags     []string          `json:"tags"`
+	Address  *bindAddress      `json:"address"`
+	Labels   map[string]string `json:"labels"`
+	Created  time.Time         `json:"created"`
+	Data     []byte            `json:"data"`
+	Extra    *JSONObject       `json:"extra"`
+	Note     string            `json:"note,omitempty"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+func TestFromStruct(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	extra := NewObject()
+	extra.Put("k", "v")
+	user := &bindUser{
+		bindBase: bindBase{ID: 9007199254740993, Version: 2},
+		Name:     "tom",
+		Age:      30,
+		Count:    5,
+		Tags:     []string{"a", "b"},
+		Address:  &bindAddress{City: "x"},
+		Labels:   map[string]string{"l": "1"},
+		Created:  created,
+		Data:     []byte("hi"),
+		Extra:    extra,
+		Ignored:  "ignored",
+		internal: "internal",
+	}
+	obj, err := FromStruct(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{"id", int64(9007199254740993)},
+		{"version", 2},
+		{"name", "tom"},
+		{"age", 30},
+		{"score", float64(0)},
+		{"active", false},
+		{"count", "5"},
+		{"tags", []interface{}{"a", "b"}},
+		{"address", map[string]interface{}{"city": "x"}},
+		{"labels", map[string]interface{}{"l": "1"}},
+		{"created", "2024-01-02T03:04:05Z"},
+		{"data", "aGk="},
+		{"extra", extra},
+	}
+	for _, test := range tests {
+		got, ok := obj.ItemMap[test.key]
+		if !ok {
+			t.Errorf("%s is missing", test.key)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s = %#v, want %#v", test.key, got, test.want)
+		}
+	}
+	for _, key := range []string{"note", "Ignored", "internal", "bindBase"} {
+		if _, ok := obj.ItemMap[key]; ok {
+			t.Errorf("%s should not be set", key)
+		}
+	}
+}
+
+func TestFromStructErrors(t *testing.T) {
+	type withFunc struct {
+		F func()
+	}
+	type node struct {
+		Name string
+		Next *node
+	}
+	loop := &node{Name: "a"}
+	loop.Next = &node{Name: "b", Next: loop}
+	selfMap := map[string]interface{}{}
+	selfMap["self"] = selfMap
+	selfSlice := []interface{}{nil}
+	selfSlice[0] = selfSlice
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"not an object", []int{1}, "can't convert"},
+		{"scalar", 1, "can't convert"},
+		{"func field", withFunc{F: func() {}}, "unsupported type"},
+		{"pointer cycle", loop, "cycle"},
+		{"map cycle", map[string]interface{}{"m": selfMap}, "cycle"},
+		{"slice cycle", map[string]interface{}{"s": selfSlice}, "cycle"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := FromStruct(test.v)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("FromStruct error = %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestFromStructSharedPointer(t *testing.T) {
+	type pair struct {
+		A *bindAddress
+		B *bindAddress
+	}
+	address := &bindAddress{City: "x"}
+	obj, err := FromStruct(pair{address, address})
+	if err != nil {
+		t.Fatalf("a pointer used twice is not a cycle: %v", err)
+	}
+	if !valuesEqual(obj.ItemMap["A"], obj.ItemMap["B"]) {
+		t.Errorf("FromStruct = %s", obj.ToJSONString())
+	}
+}
+
+func TestBind(t *testing.T) {
+	obj := mustParse(t, `{
+		"id": 9007199254740992, "version": "3", "name": "tom", "age": 30.0, "score": "1.5",
+		"active": "true", "count": "5", "tags": ["a", "b"], "address": {"city": "x"},
+		"labels": {"l": "1"}, "created": "2024-01-02T03:04:05Z", "data": "aGk=",
+		"extra": {"k": "v"}, "NOTE": "case", "unknown": 1
+	}`)
+	var user bindUser
+	if err := obj.Bind(&user); err != nil {
+		t.Fatal(err)
+	}
+	want := bindUser{
+		bindBase: bindBase{ID: 9007199254740992, Version: 3},
+		Name:     "tom",
+		Age:      30,
+		Score:    1.5,
+		Active:   true,
+		Count:    5,
+		Tags:     []string{"a", "b"},
+		Address:  &bindAddress{City: "x"},
+		Labels:   map[string]string{"l": "1"},
+		Created:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Data:     []byte("hi"),
+		Note:     "case",
+	}
+	got := user
+	got.Extra = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Bind = %+v, want %+v", got, want)
+	}
+	if user.Extra == nil || user.Extra.GetString("k") != "v" {
+		t.Errorf("Extra = %v, want {\"k\":\"v\"}", user.Extra)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	type small struct {
+		A int8    `json:"a"`
+		B uint    `json:"b"`
+		C []int   `json:"c"`
+		D bool    `json:"d"`
+		E string  `json:"e"`
+		F [1]int  `json:"f"`
+		G float32 `json:"g"`
+	}
+	tests := []struct {
+		name   string
+		doc    string
+		strict bool
+		want   string
+	}{
+		{"overflow", `{"a":300}`, false, "a: 300 overflows int8"},
+		{"negative unsigned", `{"b":-1}`, false, "b: -1 overflows uint"},
+		{"not a number", `{"a":"x"}`, false, "a:"},
+		{"not an array", `{"c":1}`, false, "c: can't convert number to []int"},
+		{"array element", `{"c":[1,"x"]}`, false, "c[1]:"},
+		{"object to string", `{"e":{}}`, false, "e: can't convert object to string"},
+		{"not a bool", `{"d":"maybe"}`, false, "d: can't convert string to bool"},
+		{"float overflow", `{"g":1e300}`, false, "g: 1e+300 overflows float32"},
+		{"strict string number", `{"a":"1"}`, true, "a: can't convert string to number"},
+		{"strict number string", `{"e":1}`, true, "e: can't convert number to string"},
+		{"strict string bool", `{"d":"true"}`, true, "d: can't convert string to bool"},
+		{"strict unknown field", `{"z":1}`, true, "z: unknown field"},
+		{"strict long array", `{"f":[1,2]}`, true, "f: array has 2 elements"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := mustParse(t, test.doc)
+			var target small
+			var err error
+			if test.strict {
+				err = obj.BindStrict(&target)
+			} else {
+				err = obj.Bind(&target)
+			}
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error = %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+	var target small
+	if err := mustParse(t, `{}`).Bind(target); err != errBindTargetPointer {
+		t.Errorf("Bind to a non pointer = %v, want errBindTargetPointer", err)
+	}
+}
+
+func TestBindStrictAcceptsMatchingTypes(t *testing.T) {
+	type target struct {
+		A int               `json:"a"`
+		B []string          `json:"b"`
+		C map[string]string `json:"c"`
+	}
+	var res target
+	if err := mustParse(t, `{"a":1,"b":["x"],"c":{"k":"v"}}`).BindStrict(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.A != 1 || len(res.B) != 1 || res.C["k"] != "v" {
+		t.Errorf("BindStrict = %+v", res)
+	}
+}
+
+func TestFromStructBindRoundTrip(t *testing.T) {
+	user := bindUser{
+		bindBase: bindBase{ID: 9007199254740993, Version: 1},
+		Name:     "tom",
+		Tags:     []string{"a"},
+		Address:  &bindAddress{City: "x", Zip: "1"},
+		Labels:   map[string]string{},
+		Created:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Data:     []byte{1, 2},
+	}
+	obj, err := FromStruct(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res bindUser
+	if err := obj.BindStrict(&res); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, user) {
+		t.Errorf("round trip = %+v, want %+v", res, user)
+	}
+}
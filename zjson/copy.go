@@ -0,0 +1,355 @@
+package zjson
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync/atomic"
+)
+
+// deepCopy copies nested objects, maps and slices of value, other values are shared.
+// Copied objects get parent as their parent.
+func deepCopy(value interface{}, parent *JSONObject) interface{} {
+	switch v := value.(type) {
+	case *JSONObject:
+		v.lock.RLock()
+		defer v.lock.RUnlock()
+		return v.copyLocked(parent)
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			res[key] = deepCopy(val, parent)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, val := range v {
+			res[i] = deepCopy(val, parent)
+		}
+		return res
+	}
+	return value
+}
+
+// copyLocked returns a deep copy of the object, the caller must hold its lock.
+func (jsonObject *JSONObject) copyLocked(parent *JSONObject) *JSONObject {
+	res := NewObject()
+	res.ordered = jsonObject.ordered
+	res.setParent(parent)
+	if jsonObject.ordered {
+		keys, tracked := jsonObject.orderedKeys()
+		res.keys = keys
+		if tracked && atomic.LoadInt32(&jsonObject.modified) == 0 {
+			res.raw = jsonObject.raw
+		} else {
+			res.modified = 1
+		}
+	}
+	if jsonObject.ItemMap == nil {
+		res.ItemMap = nil
+	}
+	for key, val := range jsonObject.ItemMap {
+		res.ItemMap[key] = deepCopy(val, res)
+	}
+	return res
+}
+
+// PlainValue converts value to the types produced by json.Unmarshal, so values holding
+// JSONObject, ArrayList, json.Number or Go integers can be compared with reflect.DeepEqual.
+// Structs and other values are converted through json.
+func PlainValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, string, float64:
+		return v
+	case *JSONObject:
+		res := make(map[string]interface{})
+		v.Each(func(key string, val interface{}) {
+			res[key] = val
+		})
+		for key, val := range res {
+			res[key] = PlainValue(val)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			res[key] = PlainValue(val)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, val := range v {
+			res[i] = PlainValue(val)
+		}
+		return res
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case arrayGetter:
+		return PlainValue(v.GetArray())
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if f, err := ToFloat64(value); err == nil {
+			return f
+		}
+	}
+	var res interface{}
+	if json.Unmarshal(ToBytes(value), &res) == nil {
+		return res
+	}
+	return value
+}
+
+func valuesEqual(a interface{}, b interface{}) bool {
+	return reflect.DeepEqual(PlainValue(a), PlainValue(b))
+}
+
+var errReadOnly = errors.New("JSONObject snapshot is read only")
+
+// Clone returns a deep copy of the object, nested objects, maps and slices are copied
+// and other values are shared.
+func (jsonObject *JSONObject) Clone() *JSONObject {
+	return deepCopy(jsonObject, nil).(*JSONObject)
+}
+
+// Snapshot returns a read only deep copy for readers. The copy is reused until the object
+// or one of its nested objects is changed through their methods, so frequent readers only
+// pay for a copy after a write. Put, Remove, Merge and MergePatch panic on a snapshot,
+// SetPath and ApplyPatch return an error.
+func (jsonObject *JSONObject) Snapshot() *JSONObject {
+	if jsonObject.frozen {
+		return jsonObject
+	}
+	jsonObject.snapLock.Lock()
+	defer jsonObject.snapLock.Unlock()
+	version := atomic.LoadInt64(&jsonObject.version)
+	if jsonObject.snap != nil && jsonObject.snapVersion == version {
+		return jsonObject.snap
+	}
+	snap := jsonObject.Clone()
+	freeze(snap)
+	jsonObject.snap = snap
+	jsonObject.snapVersion = version
+	return snap
+}
+
+// IsReadOnly returns true for objects returned by Snapshot and the objects nested in them.
+func (jsonObject *JSONObject) IsReadOnly() bool {
+	return jsonObject.frozen
+}
+
+func freeze(value interface{}) {
+	switch v := value.(type) {
+	case *JSONObject:
+		v.frozen = true
+		for _, val := range v.ItemMap {
+			freeze(val)
+		}
+	case map[string]interface{}:
+		for _, val := range v {
+			freeze(val)
+		}
+	case []interface{}:
+		for _, val := range v {
+			freeze(val)
+		}
+	}
+}
+
+func (jsonObject *JSONObject) checkWritable() {
+	if jsonObject.frozen {
+		panic(errReadOnly)
+	}
+}
+
+// adopt makes jsonObject a parent of child, so writes to child are seen by Snapshot of
+// jsonObject. A child put into several objects has all of them as parents.
+func (jsonObject *JSONObject) adopt(child *JSONObject) {
+	if child.isAncestorOf(jsonObject) {
+		return
+	}
+	child.parentLock.Lock()
+	defer child.parentLock.Unlock()
+	parents := child.getParents()
+	for _, parent := range parents {
+		if parent == jsonObject {
+			return
+		}
+	}
+	res := make([]*JSONObject, 0, len(parents)+1)
+	res = append(res, parents...)
+	child.parents.Store(append(res, jsonObject))
+}
+
+// setItem sets key to val, marks the object changed and keeps the parents of the objects
+// put and replaced up to date. The caller must hold the lock.
+func (jsonObject *JSONObject) setItem(key string, val interface{}) {
+	jsonObject.touchKey(key)
+	old := jsonObject.ItemMap[key]
+	jsonObject.ItemMap[key] = val
+	jsonObject.adoptReplaced(val, old)
+}
+
+// deleteItem removes key like setItem, the caller must hold the lock.
+func (jsonObject *JSONObject) deleteItem(key string) {
+	jsonObject.removeKey(key)
+	old := jsonObject.ItemMap[key]
+	delete(jsonObject.ItemMap, key)
+	jsonObject.release(old)
+}
+
+// adoptReplaced adopts the objects in val and releases those in old after val replaced
+// old in a map or slice held by the object. The object may be nil for values outside any
+// object, the caller must hold its lock.
+func (jsonObject *JSONObject) adoptReplaced(val interface{}, old interface{}) {
+	if jsonObject == nil || sameContainer(val, old) {
+		return
+	}
+	jsonObject.adoptAll(val)
+	jsonObject.release(old)
+}
+
+// sameContainer returns true if a and b are the same object, map or slice, which is the
+// case when a value below them changed.
+func sameContainer(a interface{}, b interface{}) bool {
+	switch v := a.(type) {
+	case *JSONObject:
+		return v == b
+	case map[string]interface{}, []interface{}:
+		if b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) {
+			return false
+		}
+		va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+		return va.Pointer() == vb.Pointer() && va.Len() == vb.Len()
+	}
+	return false
+}
+
+// adoptAll adopts the objects in value, looking into maps and slices but not into the
+// objects themselves.
+func (jsonObject *JSONObject) adoptAll(value interface{}) {
+	switch v := value.(type) {
+	case *JSONObject:
+		jsonObject.adopt(v)
+	case map[string]interface{}:
+		for _, val := range v {
+			jsonObject.adoptAll(val)
+		}
+	case []interface{}:
+		for _, val := range v {
+			jsonObject.adoptAll(val)
+		}
+	}
+}
+
+// release stops being a parent of the objects in old after old was replaced or removed,
+// unless they are still held by the object. The caller must hold the lock.
+func (jsonObject *JSONObject) release(old interface{}) {
+	switch v := old.(type) {
+	case *JSONObject:
+		if holds(jsonObject.ItemMap, v) {
+			return
+		}
+		v.replaceParents(func(parent *JSONObject) *JSONObject {
+			if parent == jsonObject {
+				return nil
+			}
+			return parent
+		})
+	case map[string]interface{}:
+		for _, val := range v {
+			jsonObject.release(val)
+		}
+	case []interface{}:
+		for _, val := range v {
+			jsonObject.release(val)
+		}
+	}
+}
+
+// holds returns true if child is value or is in the maps and slices of value.
+func holds(value interface{}, child *JSONObject) bool {
+	switch v := value.(type) {
+	case *JSONObject:
+		return v == child
+	case map[string]interface{}:
+		for _, val := range v {
+			if holds(val, child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, val := range v {
+			if holds(val, child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isAncestorOf returns true if obj is jsonObject or is below it.
+func (jsonObject *JSONObject) isAncestorOf(obj *JSONObject) bool {
+	if obj == jsonObject {
+		return true
+	}
+	for _, parent := range obj.getParents() {
+		if jsonObject.isAncestorOf(parent) {
+			return true
+		}
+	}
+	return false
+}
+
+func (jsonObject *JSONObject) getParents() []*JSONObject {
+	parents, _ := jsonObject.parents.Load().([]*JSONObject)
+	return parents
+}
+
+// getParent returns the first parent of the object.
+func (jsonObject *JSONObject) getParent() *JSONObject {
+	if parents := jsonObject.getParents(); len(parents) > 0 {
+		return parents[0]
+	}
+	return nil
+}
+
+// setParent makes parent the only parent of the object, nil removes all parents.
+func (jsonObject *JSONObject) setParent(parent *JSONObject) {
+	jsonObject.parentLock.Lock()
+	defer jsonObject.parentLock.Unlock()
+	if parent == nil {
+		jsonObject.parents.Store([]*JSONObject(nil))
+		return
+	}
+	jsonObject.parents.Store([]*JSONObject{parent})
+}
+
+// replaceParent replaces from by to in the parents of the object.
+func (jsonObject *JSONObject) replaceParent(from *JSONObject, to *JSONObject) {
+	jsonObject.replaceParents(func(parent *JSONObject) *JSONObject {
+		if parent == from {
+			return to
+		}
+		return parent
+	})
+}
+
+// replaceParents replaces every parent by the result of f, nil results are removed.
+func (jsonObject *JSONObject) replaceParents(f func(parent *JSONObject) *JSONObject) {
+	jsonObject.parentLock.Lock()
+	defer jsonObject.parentLock.Unlock()
+	var res []*JSONObject
+	for _, parent := range jsonObject.getParents() {
+		if parent = f(parent); parent != nil {
+			res = append(res, parent)
+		}
+	}
+	jsonObject.parents.Store(res)
+}
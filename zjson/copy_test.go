@@ -0,0 +1,153 @@
+package zjson
+
+import "testing"
+
+func TestSnapshotOfEveryParentSeesChildWrites(t *testing.T) {
+	child := NewObject()
+	child.Put("v", 1)
+	p1 := NewObject()
+	p2 := NewObject()
+	p1.Put("c", child)
+	p2.Put("c", child)
+	p1.Snapshot()
+	p2.Snapshot()
+	child.Put("v", 2)
+	for i, p := range []*JSONObject{p1, p2} {
+		if got := ToStr(p.Snapshot()); got != `{"c":{"v":2}}` {
+			t.Errorf("snapshot of parent %d is %s after the child changed", i+1, got)
+		}
+	}
+
+	p2.Remove("c")
+	if parents := child.getParents(); len(parents) != 1 || parents[0] != p1 {
+		t.Errorf("child parents are %v after Remove, want only p1", parents)
+	}
+}
+
+func TestSnapshotSeesChildWritesAfterSetPathAndMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		put  func(obj *JSONObject, child *JSONObject) error
+		want string
+	}{
+		{"SetPath key", func(obj *JSONObject, child *JSONObject) error {
+			return obj.SetPath("a", child)
+		}, `{"a":{"v":2}}`},
+		{"SetPath nested map", func(obj *JSONObject, child *JSONObject) error {
+			return obj.SetPath("a.b", child)
+		}, `{"a":{"b":{"v":2}}}`},
+		{"SetPath array", func(obj *JSONObject, child *JSONObject) error {
+			return obj.SetPath("a[0]", child)
+		}, `{"a":[{"v":2}]}`},
+		{"Merge into a map holding the child", func(obj *JSONObject, child *JSONObject) error {
+			if err := obj.SetPath("a.b", child); err != nil {
+				return err
+			}
+			other := ParseBytes([]byte(`{"a":{"c":1}}`))
+			obj.Merge(other, MergeReplaceArrays)
+			return nil
+		}, `{"a":{"b":{"v":2},"c":1}}`},
+		{"MergePatch next to the child", func(obj *JSONObject, child *JSONObject) error {
+			obj.Put("a", child)
+			obj.MergePatch(ParseBytes([]byte(`{"b":1}`)))
+			return nil
+		}, `{"a":{"v":2},"b":1}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := NewObject()
+			child := NewObject()
+			child.Put("v", 1)
+			if err := test.put(obj, child); err != nil {
+				t.Fatal(err)
+			}
+			obj.Snapshot()
+			child.Put("v", 2)
+			if got := ToStr(obj.Snapshot()); got != test.want {
+				t.Errorf("snapshot is %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplacedChildIsReleased(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace func(obj *JSONObject) error
+	}{
+		{"SetPath", func(obj *JSONObject) error {
+			return obj.SetPath("a", 1)
+		}},
+		{"Merge", func(obj *JSONObject) error {
+			obj.Merge(ParseBytes([]byte(`{"a":1}`)), MergeReplaceArrays)
+			return nil
+		}},
+		{"ApplyPatch", func(obj *JSONObject) error {
+			return obj.ApplyPatch([]PatchOp{{Op: "add", Path: "/b", Value: 1}})
+		}},
+		{"MergePatch null", func(obj *JSONObject) error {
+			obj.MergePatch(ParseBytes([]byte(`{"a":null}`)))
+			return nil
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := NewObject()
+			child := NewObject()
+			obj.Put("a", child)
+			if err := test.replace(obj); err != nil {
+				t.Fatal(err)
+			}
+			if parents := child.getParents(); len(parents) != 0 {
+				t.Errorf("replaced child still has parents %v", parents)
+			}
+		})
+	}
+}
+
+func TestGetJSONObjectKeepsStoredMap(t *testing.T) {
+	obj := ParseBytes([]byte(`{"a":{"v":1}}`))
+	nested, err := obj.GetJSONObject("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested.Put("v", 2)
+	m, ok := obj.ItemMap["a"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("GetJSONObject replaced the stored map by %T", obj.ItemMap["a"])
+	}
+	if m["v"] != 2 {
+		t.Errorf("write through GetJSONObject not seen in the map, v is %v", m["v"])
+	}
+
+	promoted, err := obj.PromoteJSONObject("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := obj.PromoteJSONObject("a"); again != promoted {
+		t.Error("PromoteJSONObject returned another object the second time")
+	}
+	if got, _ := obj.GetJSONObject("a"); got != promoted {
+		t.Error("GetJSONObject didn't return the promoted object")
+	}
+	obj.Put("s", "x")
+	if _, err := obj.PromoteJSONObject("s"); err == nil {
+		t.Error("PromoteJSONObject accepted a string")
+	}
+	if _, err := obj.PromoteJSONObject("missing"); err == nil {
+		t.Error("PromoteJSONObject accepted a missing key")
+	}
+}
+
+func TestParseJSONObjectCopiesObject(t *testing.T) {
+	a := NewObject()
+	a.Put("v", 1)
+	b, err := ParseJSONObject(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Put("v", 2)
+	if a.GetInt("v") != 1 {
+		t.Error("changing the result of ParseJSONObject changed its argument")
+	}
+}
@@ -0,0 +1,622 @@
+package zjson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/shopspring/decimal"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+	"gopkg.in/yaml.v3"
+)
+
+// The YAML, TOML and MessagePack decoders return integers as int64 (uint64 above the int64
+// range), floats as float64, and bool, string and nil as is, so an integer stays an integer
+// and 1.0 stays a float. Timestamps are time.Time and MessagePack binary is []byte.
+// Objects are encoded in key order for ordered objects, sorted otherwise.
+
+// ParseYAML parses a yaml mapping into an ordered object keeping the order of the keys,
+// nested mappings are ordered objects too. Anchors, aliases and << merge keys are resolved.
+// An empty document gives an empty object.
+func ParseYAML(data []byte) (*JSONObject, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if len(node.Content) == 0 {
+		return NewOrderedObject(), nil
+	}
+	value, err := yamlValue(&node, nil)
+	if err != nil {
+		return nil, err
+	}
+	jsonObject, ok := value.(*JSONObject)
+	if !ok {
+		return nil, errors.New("ParseYAML data is not a yaml mapping")
+	}
+	return jsonObject, nil
+}
+
+// ParseYAMLArray parses a yaml sequence.
+func ParseYAMLArray(data []byte) ([]interface{}, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if len(node.Content) == 0 {
+		return make([]interface{}, 0), nil
+	}
+	value, err := yamlValue(&node, nil)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("ParseYAMLArray data is not a yaml sequence")
+	}
+	return arr, nil
+}
+
+// ToYAML encodes value as yaml, value may be a JSONObject, an ArrayList, a map, a slice
+// or anything json.Marshal accepts.
+func ToYAML(value interface{}) ([]byte, error) {
+	value, err := encodable(value)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(yamlNode(value)); err != nil {
+		return nil, err
+	}
+	if err = encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (jsonObject *JSONObject) ToYAML() ([]byte, error) {
+	return ToYAML(jsonObject)
+}
+
+// ParseTOML parses a toml document. TOML doesn't keep a meaningful key order, so the
+// object is not ordered and nested tables are maps.
+func ParseTOML(data []byte) (*JSONObject, error) {
+	var m map[string]interface{}
+	if _, err := toml.Decode(string(data), &m); err != nil {
+		return nil, err
+	}
+	jsonObject := NewObject()
+	for key, val := range m {
+		jsonObject.ItemMap[key] = decodedValue(val)
+	}
+	return jsonObject, nil
+}
+
+// ToTOML encodes value as a toml document, value must be an object. TOML has no null,
+// so a nil value anywhere in value is an error, and []byte values are written as base64.
+func ToTOML(value interface{}) ([]byte, error) {
+	value, err := encodable(value)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.(*entries); !ok {
+		return nil, errors.New("ToTOML value is not an object")
+	}
+	tree, err := tomlValue(value, "")
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = toml.NewEncoder(&buf).Encode(tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (jsonObject *JSONObject) ToTOML() ([]byte, error) {
+	return ToTOML(jsonObject)
+}
+
+// ParseMsgPack parses a MessagePack map into an ordered object keeping the order of the
+// keys, nested maps are ordered objects too. Keys which are not strings are converted
+// with ToStr.
+func ParseMsgPack(data []byte) (*JSONObject, error) {
+	value, err := msgPackValue(msgpack.NewDecoder(bytes.NewReader(data)), nil)
+	if err != nil {
+		return nil, err
+	}
+	jsonObject, ok := value.(*JSONObject)
+	if !ok {
+		return nil, errors.New("ParseMsgPack data is not a MessagePack map")
+	}
+	return jsonObject, nil
+}
+
+// ParseMsgPackArray parses a MessagePack array.
+func ParseMsgPackArray(data []byte) ([]interface{}, error) {
+	value, err := msgPackValue(msgpack.NewDecoder(bytes.NewReader(data)), nil)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("ParseMsgPackArray data is not a MessagePack array")
+	}
+	return arr, nil
+}
+
+// ToMsgPack encodes value as MessagePack, integers use the smallest encoding and floats
+// are always 64 bit.
+func ToMsgPack(value interface{}) ([]byte, error) {
+	value, err := encodable(value)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = writeMsgPack(msgpack.NewEncoder(&buf), value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (jsonObject *JSONObject) ToMsgPack() ([]byte, error) {
+	return ToMsgPack(jsonObject)
+}
+
+// entries is an object with the order its keys are encoded in.
+type entries struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// encodable converts value to nil, bool, int64, uint64, float64, string, time.Time,
+// []byte, []interface{} and *entries, the values the encoders write.
+func encodable(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, int64, uint64, float64, string, time.Time, []byte:
+		return v, nil
+	case *JSONObject:
+		if v == nil {
+			return nil, nil
+		}
+		keys, items := snapshotItems(v)
+		return encodableEntries(keys, items)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return encodableEntries(keys, v)
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, val := range v {
+			converted, err := encodable(val)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = converted
+		}
+		return arr, nil
+	case arrayGetter:
+		return encodable(v.GetArray())
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u, nil
+		}
+		return strconv.ParseFloat(string(v), 64)
+	case decimal.Decimal:
+		if v.Equal(v.Truncate(0)) {
+			if i, err := decimalToInt64(v); err == nil {
+				return i, nil
+			}
+		}
+		f, _ := v.Float64()
+		return f, nil
+	case *decimal.Decimal:
+		if v == nil {
+			return nil, nil
+		}
+		return encodable(*v)
+	case float32:
+		return float32To64(v), nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var res interface{}
+	if err = decoder.Decode(&res); err != nil {
+		return nil, err
+	}
+	return encodable(res)
+}
+
+func encodableEntries(keys []string, items map[string]interface{}) (*entries, error) {
+	res := &entries{keys: keys, values: make(map[string]interface{}, len(keys))}
+	for _, key := range keys {
+		val, err := encodable(items[key])
+		if err != nil {
+			return nil, err
+		}
+		res.values[key] = val
+	}
+	return res, nil
+}
+
+// decodedValue converts a value decoded by a yaml, toml or MessagePack decoder to the
+// types documented above.
+func decodedValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return decodedValue(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+		return v
+	case float32:
+		return float32To64(v)
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = decodedValue(val)
+		}
+		return v
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			res[keyString(key)] = decodedValue(val)
+		}
+		return res
+	case []interface{}:
+		for i, val := range v {
+			v[i] = decodedValue(val)
+		}
+		return v
+	case []map[string]interface{}:
+		res := make([]interface{}, len(v))
+		for i, val := range v {
+			res[i] = decodedValue(val)
+		}
+		return res
+	}
+	return value
+}
+
+// float32To64 keeps the shortest decimal form, so float32(1.1) gives 1.1.
+func float32To64(f float32) float64 {
+	res, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return res
+}
+
+func keyString(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case []byte:
+		return string(k)
+	case nil:
+		return "null"
+	case time.Time:
+		return k.Format(time.RFC3339Nano)
+	}
+	return ToStr(decodedValue(key))
+}
+
+func yamlValue(node *yaml.Node, parent *JSONObject) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(node.Content[0], parent)
+	case yaml.AliasNode:
+		return yamlValue(node.Alias, parent)
+	case yaml.SequenceNode:
+		arr := make([]interface{}, len(node.Content))
+		for i, child := range node.Content {
+			value, err := yamlValue(child, parent)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = value
+		}
+		return arr, nil
+	case yaml.MappingNode:
+		jsonObject := NewOrderedObject()
+		jsonObject.setParent(parent)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valNode := node.Content[i], node.Content[i+1]
+			if keyNode.Kind == yaml.AliasNode {
+				keyNode = keyNode.Alias
+			}
+			if keyNode.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("yaml line %d: mapping key is not a scalar", keyNode.Line)
+			}
+			if keyNode.Tag == "!!merge" {
+				if err := yamlMerge(jsonObject, valNode); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			value, err := yamlValue(valNode, jsonObject)
+			if err != nil {
+				return nil, err
+			}
+			putDecoded(jsonObject, keyNode.Value, value)
+		}
+		return jsonObject, nil
+	default:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, err
+		}
+		return decodedValue(value), nil
+	}
+}
+
+// yamlMerge adds the keys of the mappings merged by a << key which are not set yet, a key
+// set after the << key replaces the merged value.
+func yamlMerge(jsonObject *JSONObject, node *yaml.Node) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	sources := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		sources = node.Content
+	}
+	for _, source := range sources {
+		value, err := yamlValue(source, jsonObject)
+		if err != nil {
+			return err
+		}
+		merged, ok := value.(*JSONObject)
+		if !ok {
+			return fmt.Errorf("yaml line %d: << value is not a mapping", source.Line)
+		}
+		for _, key := range merged.keys {
+			if _, ok := jsonObject.ItemMap[key]; ok {
+				continue
+			}
+			val := merged.ItemMap[key]
+			if obj, ok := val.(*JSONObject); ok {
+				obj.setParent(jsonObject)
+			}
+			putDecoded(jsonObject, key, val)
+		}
+	}
+	return nil
+}
+
+// putDecoded sets key on an object which is still being decoded.
+func putDecoded(jsonObject *JSONObject, key string, value interface{}) {
+	if _, ok := jsonObject.ItemMap[key]; !ok {
+		jsonObject.keys = append(jsonObject.keys, key)
+	}
+	jsonObject.ItemMap[key] = value
+}
+
+func yamlNode(value interface{}) *yaml.Node {
+	scalar := func(tag string, val string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: val}
+	}
+	switch v := value.(type) {
+	case nil:
+		return scalar("!!null", "null")
+	case bool:
+		return scalar("!!bool", strconv.FormatBool(v))
+	case int64:
+		return scalar("!!int", strconv.FormatInt(v, 10))
+	case uint64:
+		return scalar("!!int", strconv.FormatUint(v, 10))
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return scalar("!!float", ".nan")
+		case math.IsInf(v, 1):
+			return scalar("!!float", ".inf")
+		case math.IsInf(v, -1):
+			return scalar("!!float", "-.inf")
+		}
+		return scalar("!!float", formatFloat(v))
+	case string:
+		node := scalar("!!str", v)
+		if strings.Contains(v, "\n") {
+			node.Style = yaml.LiteralStyle
+		}
+		return node
+	case time.Time:
+		return scalar("!!timestamp", v.Format(time.RFC3339Nano))
+	case []byte:
+		return scalar("!!binary", base64.StdEncoding.EncodeToString(v))
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, val := range v {
+			node.Content = append(node.Content, yamlNode(val))
+		}
+		return node
+	case *entries:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range v.keys {
+			node.Content = append(node.Content, scalar("!!str", key), yamlNode(v.values[key]))
+		}
+		return node
+	}
+	return scalar("!!str", ToStr(value))
+}
+
+// formatFloat writes f so that it is read back as a float, 1 is written as 1.0.
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func tomlValue(value interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("toml has no null value, found one at %q", path)
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("toml integers are signed 64 bit, %d at %q is too large", v, path)
+		}
+		return int64(v), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, val := range v {
+			converted, err := tomlValue(val, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = converted
+		}
+		return arr, nil
+	case *entries:
+		m := make(map[string]interface{}, len(v.keys))
+		for _, key := range v.keys {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			converted, err := tomlValue(v.values[key], childPath)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = converted
+		}
+		return m, nil
+	}
+	return value, nil
+}
+
+func msgPackValue(decoder *msgpack.Decoder, parent *JSONObject) (interface{}, error) {
+	code, err := decoder.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32:
+		n, err := decoder.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+		jsonObject := NewOrderedObject()
+		jsonObject.setParent(parent)
+		for i := 0; i < n; i++ {
+			key, err := decoder.DecodeInterface()
+			if err != nil {
+				return nil, err
+			}
+			value, err := msgPackValue(decoder, jsonObject)
+			if err != nil {
+				return nil, err
+			}
+			putDecoded(jsonObject, keyString(key), value)
+		}
+		return jsonObject, nil
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		n, err := decoder.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, n)
+		for i := 0; i < n; i++ {
+			if arr[i], err = msgPackValue(decoder, parent); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	value, err := decoder.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	return decodedValue(value), nil
+}
+
+func writeMsgPack(encoder *msgpack.Encoder, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return encoder.EncodeNil()
+	case bool:
+		return encoder.EncodeBool(v)
+	case int64:
+		return encoder.EncodeInt(v)
+	case uint64:
+		return encoder.EncodeUint(v)
+	case float64:
+		return encoder.EncodeFloat64(v)
+	case string:
+		return encoder.EncodeString(v)
+	case time.Time:
+		return encoder.EncodeTime(v)
+	case []byte:
+		return encoder.EncodeBytes(v)
+	case []interface{}:
+		if err := encoder.EncodeArrayLen(len(v)); err != nil {
+			return err
+		}
+		for _, val := range v {
+			if err := writeMsgPack(encoder, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *entries:
+		if err := encoder.EncodeMapLen(len(v.keys)); err != nil {
+			return err
+		}
+		for _, key := range v.keys {
+			if err := encoder.EncodeString(key); err != nil {
+				return err
+			}
+			if err := writeMsgPack(encoder, v.values[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return encoder.Encode(value)
+}
@@ -0,0 +1,226 @@
+package zjson
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// formatsDoc returns an ordered object holding every type the formats keep.
+func formatsDoc() *JSONObject {
+	nested := NewOrderedObject()
+	nested.Put("z", int64(1))
+	nested.Put("a", "x")
+	obj := NewOrderedObject()
+	obj.Put("int", int64(-3))
+	obj.Put("float", 1.0)
+	obj.Put("frac", 0.1)
+	obj.Put("big", int64(math.MaxInt64))
+	obj.Put("bool", true)
+	obj.Put("str", "hello")
+	obj.Put("time", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	obj.Put("list", []interface{}{int64(1), "two", 3.5})
+	obj.Put("nested", nested)
+	return obj
+}
+
+func TestFormatsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		encode  func(obj *JSONObject) ([]byte, error)
+		decode  func(data []byte) (*JSONObject, error)
+		ordered bool
+	}{
+		{"yaml", (*JSONObject).ToYAML, ParseYAML, true},
+		{"toml", (*JSONObject).ToTOML, ParseTOML, false},
+		{"msgpack", (*JSONObject).ToMsgPack, ParseMsgPack, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := formatsDoc()
+			data, err := test.encode(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := test.decode(data)
+			if err != nil {
+				t.Fatalf("decode %s: %v", data, err)
+			}
+			for _, key := range doc.Keys() {
+				want := doc.Get(key)
+				value := got.Get(key)
+				if nested, ok := want.(*JSONObject); ok {
+					if !valuesEqual(value, nested) {
+						t.Errorf("%s = %v, want %v", key, value, nested)
+					}
+					continue
+				}
+				if wantTime, ok := want.(time.Time); ok {
+					if gotTime, ok := value.(time.Time); !ok || !gotTime.Equal(wantTime) {
+						t.Errorf("%s = %#v, want %v", key, value, wantTime)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(value, want) {
+					t.Errorf("%s = %#v, want %#v", key, value, want)
+				}
+			}
+			if test.ordered {
+				if keys := got.Keys(); !reflect.DeepEqual(keys, doc.Keys()) {
+					t.Errorf("keys = %v, want %v", keys, doc.Keys())
+				}
+				nested, _ := got.GetJSONObject("nested")
+				if keys := nested.Keys(); !reflect.DeepEqual(keys, []string{"z", "a"}) {
+					t.Errorf("nested keys = %v, want [z a]", keys)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatsNull(t *testing.T) {
+	obj := NewObject()
+	obj.Put("n", nil)
+	for name, encode := range map[string]func(obj *JSONObject) ([]byte, error){
+		"yaml": (*JSONObject).ToYAML, "msgpack": (*JSONObject).ToMsgPack,
+	} {
+		data, err := encode(obj)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var got *JSONObject
+		if name == "yaml" {
+			got, err = ParseYAML(data)
+		} else {
+			got, err = ParseMsgPack(data)
+		}
+		if err != nil || !got.Contains("n") || got.Get("n") != nil {
+			t.Errorf("%s: null round trip = %v, %v", name, got, err)
+		}
+	}
+	if _, err := obj.ToTOML(); err == nil || !strings.Contains(err.Error(), "null") {
+		t.Errorf("ToTOML with a null = %v, want an error", err)
+	}
+}
+
+func TestFormatsNumbers(t *testing.T) {
+	obj, err := ParsePrecise([]byte(`{"id":9007199254740993,"u":18446744073709551615,"f":0.5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.Put("f32", float32(1.1))
+	obj.Put("i8", int8(-8))
+	data, err := obj.ToMsgPack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseMsgPack(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"id":  int64(9007199254740993),
+		"u":   uint64(18446744073709551615),
+		"f":   0.5,
+		"f32": 1.1,
+		"i8":  int64(-8),
+	}
+	for key, val := range want {
+		if got.Get(key) != val {
+			t.Errorf("%s = %#v, want %#v", key, got.Get(key), val)
+		}
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	data := []byte(`
+base: &base
+  host: localhost
+  port: 80
+dev:
+  <<: *base
+  port: 8080
+tags: [a, b]
+empty: ~
+ratio: 1e3
+`)
+	obj, err := ParseYAML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, err := obj.GetJSONObject("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.GetString("host") != "localhost" || dev.Get("port") != int64(8080) {
+		t.Errorf("dev = %s, want the merged base with port 8080", dev.ToJSONString())
+	}
+	if keys := dev.Keys(); !reflect.DeepEqual(keys, []string{"host", "port"}) {
+		t.Errorf("dev keys = %v, want the merged keys where << is", keys)
+	}
+	if !obj.Contains("empty") || obj.Get("empty") != nil {
+		t.Errorf("empty = %#v, want nil", obj.Get("empty"))
+	}
+	if obj.Get("ratio") != 1000.0 {
+		t.Errorf("ratio = %#v, want 1000.0", obj.Get("ratio"))
+	}
+	if empty, err := ParseYAML(nil); err != nil || empty.Size() != 0 {
+		t.Errorf("ParseYAML of an empty document = %v, %v", empty, err)
+	}
+	if arr, err := ParseYAMLArray([]byte("- 1\n- x\n")); err != nil || !reflect.DeepEqual(arr, []interface{}{int64(1), "x"}) {
+		t.Errorf("ParseYAMLArray = %#v, %v", arr, err)
+	}
+}
+
+func TestFormatsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func() error
+	}{
+		{"yaml sequence", func() error { _, err := ParseYAML([]byte("- 1")); return err }},
+		{"yaml mapping as array", func() error { _, err := ParseYAMLArray([]byte("a: 1")); return err }},
+		{"invalid yaml", func() error { _, err := ParseYAML([]byte("a: [")); return err }},
+		{"yaml merge of a scalar", func() error { _, err := ParseYAML([]byte("a: &x 1\nb:\n  <<: *x")); return err }},
+		{"invalid toml", func() error { _, err := ParseTOML([]byte("a = ")); return err }},
+		{"toml array", func() error { _, err := ToTOML([]interface{}{1}); return err }},
+		{"toml uint64", func() error { _, err := ToTOML(map[string]interface{}{"u": uint64(math.MaxUint64)}); return err }},
+		{"msgpack array as object", func() error {
+			data, _ := ToMsgPack([]interface{}{1})
+			_, err := ParseMsgPack(data)
+			return err
+		}},
+		{"msgpack map as array", func() error {
+			data, _ := ToMsgPack(NewObject())
+			_, err := ParseMsgPackArray(data)
+			return err
+		}},
+		{"truncated msgpack", func() error { _, err := ParseMsgPack([]byte{0x81, 0xa1}); return err }},
+		{"unsupported value", func() error { _, err := ToYAML(map[string]interface{}{"f": func() {}}); return err }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.f(); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
+
+func TestMsgPackKeys(t *testing.T) {
+	data, err := ToMsgPack(map[string]interface{}{"b": []byte("raw"), "a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := ParseMsgPack(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := obj.Keys(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("keys of a map = %v, want sorted", keys)
+	}
+	if b, ok := obj.Get("b").([]byte); !ok || string(b) != "raw" {
+		t.Errorf("b = %#v, want []byte", obj.Get("b"))
+	}
+}
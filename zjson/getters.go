@@ -0,0 +1,112 @@
+package zjson
+
+import "fmt"
+
+// GetE returns the value of key, or an error if key doesn't exist.
+func (jsonObject *JSONObject) GetE(key string) (interface{}, error) {
+	jsonObject.lock.RLock()
+	defer jsonObject.lock.RUnlock()
+	value, ok := jsonObject.ItemMap[key]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found", key)
+	}
+	return value, nil
+}
+
+func (jsonObject *JSONObject) GetStringE(key string) (string, error) {
+	value, err := jsonObject.GetE(key)
+	if err != nil {
+		return "", err
+	}
+	return ToStr(value), nil
+}
+
+func (jsonObject *JSONObject) GetIntE(key string) (int, error) {
+	value, err := jsonObject.GetE(key)
+	if err != nil {
+		return 0, err
+	}
+	res, err := ToInt(value)
+	if err != nil {
+		return 0, fmt.Errorf("key %s: %v", key, err)
+	}
+	return res, nil
+}
+
+func (jsonObject *JSONObject) GetInt64E(key string) (int64, error) {
+	value, err := jsonObject.GetE(key)
+	if err != nil {
+		return 0, err
+	}
+	res, err := ToInt64(value)
+	if err != nil {
+		return 0, fmt.Errorf("key %s: %v", key, err)
+	}
+	return res, nil
+}
+
+func (jsonObject *JSONObject) GetFloatE(key string) (float64, error) {
+	value, err := jsonObject.GetE(key)
+	if err != nil {
+		return 0, err
+	}
+	res, err := ToFloat64(value)
+	if err != nil {
+		return 0, fmt.Errorf("key %s: %v", key, err)
+	}
+	return res, nil
+}
+
+func (jsonObject *JSONObject) GetBoolE(key string) (bool, error) {
+	value, err := jsonObject.GetE(key)
+	if err != nil {
+		return false, err
+	}
+	res, err := ToBool(value)
+	if err != nil {
+		return false, fmt.Errorf("key %s: %v", key, err)
+	}
+	return res, nil
+}
+
+// GetStringOr returns def if key doesn't exist or its value is nil.
+func (jsonObject *JSONObject) GetStringOr(key string, def string) string {
+	value := jsonObject.Get(key)
+	if value == nil {
+		return def
+	}
+	return ToStr(value)
+}
+
+// GetIntOr returns def if key doesn't exist or can't be converted.
+func (jsonObject *JSONObject) GetIntOr(key string, def int) int {
+	res, err := jsonObject.GetIntE(key)
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (jsonObject *JSONObject) GetInt64Or(key string, def int64) int64 {
+	res, err := jsonObject.GetInt64E(key)
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (jsonObject *JSONObject) GetFloatOr(key string, def float64) float64 {
+	res, err := jsonObject.GetFloatE(key)
+	if err != nil {
+		return def
+	}
+	return res
+}
+
+func (jsonObject *JSONObject) GetBoolOr(key string, def bool) bool {
+	res, err := jsonObject.GetBoolE(key)
+	if err != nil {
+		return def
+	}
+	return res
+}
@@ -0,0 +1,207 @@
+package zjson
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func gettersDoc(t *testing.T) *JSONObject {
+	obj := mustParse(t, `{"int":3,"float":3.5,"exp":1e+21,"big":1e6,"numstr":"42","expstr":"1e+06",
+		"bool":true,"boolstr":"false","boolnum":1,"str":"abc","null":null,"obj":{"a":1}}`)
+	obj.Put("int64", int64(math.MaxInt64))
+	obj.Put("number", json.Number("9007199254740993"))
+	obj.Put("uint64", uint64(math.MaxUint64))
+	return obj
+}
+
+func TestGetIntE(t *testing.T) {
+	tests := []struct {
+		key  string
+		want int64
+		ok   bool
+	}{
+		{"int", 3, true},
+		{"big", 1000000, true},
+		{"numstr", 42, true},
+		{"expstr", 1000000, true},
+		{"int64", math.MaxInt64, true},
+		{"number", 9007199254740993, true},
+		{"float", 0, false},
+		{"exp", 0, false},
+		{"uint64", 0, false},
+		{"str", 0, false},
+		{"null", 0, false},
+		{"obj", 0, false},
+		{"missing", 0, false},
+	}
+	obj := gettersDoc(t)
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			got, err := obj.GetInt64E(test.key)
+			if test.ok && (err != nil || got != test.want) {
+				t.Errorf("GetInt64E = %d, %v, want %d", got, err, test.want)
+			}
+			if !test.ok && err == nil {
+				t.Errorf("GetInt64E = %d, want an error", got)
+			}
+			if def := obj.GetInt64Or(test.key, -1); test.ok && def != test.want || !test.ok && def != -1 {
+				t.Errorf("GetInt64Or = %d", def)
+			}
+			if int64(int(test.want)) != test.want {
+				return
+			}
+			n, err := obj.GetIntE(test.key)
+			if test.ok && (err != nil || int64(n) != test.want) {
+				t.Errorf("GetIntE = %d, %v, want %d", n, err, test.want)
+			}
+			if !test.ok && err == nil {
+				t.Errorf("GetIntE = %d, want an error", n)
+			}
+			if def := obj.GetIntOr(test.key, -1); test.ok && int64(def) != test.want || !test.ok && def != -1 {
+				t.Errorf("GetIntOr = %d", def)
+			}
+		})
+	}
+}
+
+func TestGetFloatE(t *testing.T) {
+	tests := []struct {
+		key  string
+		want float64
+		ok   bool
+	}{
+		{"int", 3, true},
+		{"float", 3.5, true},
+		{"exp", 1e21, true},
+		{"numstr", 42, true},
+		{"number", 9007199254740993, true},
+		{"str", 0, false},
+		{"null", 0, false},
+		{"missing", 0, false},
+	}
+	obj := gettersDoc(t)
+	for _, test := range tests {
+		got, err := obj.GetFloatE(test.key)
+		if test.ok && (err != nil || got != test.want) {
+			t.Errorf("GetFloatE(%s) = %v, %v, want %v", test.key, got, err, test.want)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("GetFloatE(%s) = %v, want an error", test.key, got)
+		}
+		if def := obj.GetFloatOr(test.key, -1); test.ok && def != test.want || !test.ok && def != -1 {
+			t.Errorf("GetFloatOr(%s) = %v", test.key, def)
+		}
+	}
+}
+
+func TestGetBoolE(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+		ok   bool
+	}{
+		{"bool", true, true},
+		{"boolstr", false, true},
+		{"boolnum", true, true},
+		{"str", false, false},
+		{"int", false, false},
+		{"null", false, false},
+		{"missing", false, false},
+	}
+	obj := gettersDoc(t)
+	for _, test := range tests {
+		got, err := obj.GetBoolE(test.key)
+		if test.ok && (err != nil || got != test.want) {
+			t.Errorf("GetBoolE(%s) = %v, %v, want %v", test.key, got, err, test.want)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("GetBoolE(%s) = %v, want an error", test.key, got)
+		}
+		if def := obj.GetBoolOr(test.key, true); test.ok && def != test.want || !test.ok && !def {
+			t.Errorf("GetBoolOr(%s) = %v", test.key, def)
+		}
+	}
+}
+
+func TestGetStringE(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"str", "abc", true},
+		{"int", "3", true},
+		{"float", "3.5", true},
+		{"exp", "1000000000000000000000", true},
+		{"number", "9007199254740993", true},
+		{"obj", `{"a":1}`, true},
+		{"null", "", true},
+		{"missing", "", false},
+	}
+	obj := gettersDoc(t)
+	for _, test := range tests {
+		got, err := obj.GetStringE(test.key)
+		if test.ok && (err != nil || got != test.want) {
+			t.Errorf("GetStringE(%s) = %q, %v, want %q", test.key, got, err, test.want)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("GetStringE(%s) = %q, want an error", test.key, got)
+		}
+	}
+	if got := obj.GetStringOr("null", "def"); got != "def" {
+		t.Errorf("GetStringOr of null = %q, want def", got)
+	}
+	if got := obj.GetStringOr("missing", "def"); got != "def" {
+		t.Errorf("GetStringOr of a missing key = %q, want def", got)
+	}
+	if got := obj.GetStringOr("int", "def"); got != "3" {
+		t.Errorf("GetStringOr(int) = %q, want 3", got)
+	}
+}
+
+func TestGetIntEErrorNamesKey(t *testing.T) {
+	obj := gettersDoc(t)
+	if _, err := obj.GetIntE("str"); err == nil || err.Error()[:7] != "key str" {
+		t.Errorf("GetIntE error = %v, want it to name the key", err)
+	}
+	if _, err := obj.GetIntE("missing"); err == nil || err.Error() != "key missing not found" {
+		t.Errorf("GetIntE error = %v, want key missing not found", err)
+	}
+}
+
+func TestToIntConversions(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  int64
+		ok    bool
+	}{
+		{float64(3), 3, true},
+		{float64(1e18), 1000000000000000000, true},
+		{float64(-1e18), -1000000000000000000, true},
+		{float64(math.MaxInt64), 0, false},
+		{float64(1.5), 0, false},
+		{math.NaN(), 0, false},
+		{math.Inf(1), 0, false},
+		{"1e18", 1000000000000000000, true},
+		{"9223372036854775807", math.MaxInt64, true},
+		{"9223372036854775808", 0, false},
+		{"3.0", 3, true},
+		{"3.5", 0, false},
+		{float32(2), 2, true},
+		{uint(7), 7, true},
+		{int8(-7), -7, true},
+	}
+	for _, test := range tests {
+		got, err := ToInt64(test.value)
+		if test.ok && (err != nil || got != test.want) {
+			t.Errorf("ToInt64(%#v) = %d, %v, want %d", test.value, got, err, test.want)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("ToInt64(%#v) = %d, want an error", test.value, got)
+		}
+	}
+	if _, err := ToInt32(int64(math.MaxInt32) + 1); err == nil {
+		t.Error("ToInt32 above MaxInt32 returned no error")
+	}
+}
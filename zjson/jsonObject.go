@@ -4,17 +4,37 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/shopspring/decimal"
 )
 
+// JSONObject is safe for concurrent use through its methods. Each object has its own
+// lock, nested objects are safe to change concurrently once PromoteJSONObject stored them
+// as a *JSONObject. Writes through the methods of a nested object are seen by the objects
+// containing it, see Snapshot. ItemMap and values returned by Get, like slices and plain
+// maps, are not protected, use Clone to get a private copy.
 type JSONObject struct {
 	ItemMap map[string]interface{}
-	lock    sync.Mutex
+	lock    sync.RWMutex
+	// fields of an ordered object, see NewOrderedObject
+	ordered  bool
+	keys     []string
+	raw      []byte
+	modified int32
+	// version counts writes to the object and the objects below it
+	version     int64
+	parents     atomic.Value
+	parentLock  sync.Mutex
+	frozen      bool
+	snapLock    sync.Mutex
+	snap        *JSONObject
+	snapVersion int64
 }
 
 func NewObject() *JSONObject {
@@ -33,18 +53,18 @@ func (jsonObject *JSONObject) Put(key string, val interface{}) {
 	// }
 	jsonObject.lock.Lock()
 	defer jsonObject.lock.Unlock()
-	jsonObject.ItemMap[key] = val
+	jsonObject.setItem(key, val)
 }
 
 func (jsonObject *JSONObject) Remove(key string) {
 	jsonObject.lock.Lock()
 	defer jsonObject.lock.Unlock()
-	delete(jsonObject.ItemMap, key)
+	jsonObject.deleteItem(key)
 }
 
 func (jsonObject *JSONObject) Contains(key string) bool {
-	jsonObject.lock.Lock()
-	defer jsonObject.lock.Unlock()
+	jsonObject.lock.RLock()
+	defer jsonObject.lock.RUnlock()
 	var _, ok = jsonObject.ItemMap[key]
 	return ok
 }
@@ -68,66 +88,113 @@ func (jsonObject *JSONObject) GetInt64(key string) int64 {
 }
 
 func ToInt(value interface{}) (int, error) {
-	switch value := value.(type) {
-	case string:
-		r, err := strconv.Atoi(value)
-		return r, err
-	case int:
-		return value, nil
-	case int64:
-		return int(value), nil
-	case int32:
-		return int(value), nil
-	default:
-		r, err := strconv.Atoi(ToStr(value))
-		return r, err
+	r, err := ToInt64(value)
+	if err != nil {
+		return 0, err
 	}
+	if int64(int(r)) != r {
+		return 0, fmt.Errorf("%v overflows int", r)
+	}
+	return int(r), nil
 }
 
 func ToInt32(value interface{}) (int32, error) {
-	switch value := value.(type) {
-	case string:
-		r, err := strconv.Atoi(value)
-		if err != nil {
-			return 0, err
-		}
-		return int32(r), err
-	case int:
-		return int32(value), nil
-	case int32:
-		return value, nil
-	case int64:
-		return int32(value), nil
-	default:
-		r, err := strconv.Atoi(ToStr(value))
-		if err != nil {
-			return 0, err
-		}
-		return int32(r), err
+	r, err := ToInt64(value)
+	if err != nil {
+		return 0, err
 	}
+	if r < math.MinInt32 || r > math.MaxInt32 {
+		return 0, fmt.Errorf("%v overflows int32", r)
+	}
+	return int32(r), nil
 }
 
+// ToInt64 converts integers, integral floats (as decoded by json.Unmarshal) and
+// numeric strings including exponent form like "1e+06".
 func ToInt64(value interface{}) (int64, error) {
 	switch value := value.(type) {
 	case string:
-		r, err := strconv.Atoi(value)
-		if err != nil {
-			return 0, err
+		return parseInt64(value)
+	case json.Number:
+		return parseInt64(string(value))
+	case decimal.Decimal:
+		return decimalToInt64(value)
+	case *decimal.Decimal:
+		if value == nil {
+			return 0, errors.New("can't convert nil to int")
 		}
-		return int64(r), err
+		return decimalToInt64(*value)
 	case int:
 		return int64(value), nil
 	case int64:
 		return value, nil
 	case int32:
 		return int64(value), nil
-	default:
-		r, err := strconv.Atoi(ToStr(value))
-		if err != nil {
-			return 0, err
+	case int16:
+		return int64(value), nil
+	case int8:
+		return int64(value), nil
+	case uint8:
+		return int64(value), nil
+	case uint16:
+		return int64(value), nil
+	case uint32:
+		return int64(value), nil
+	case uint:
+		if uint64(value) > math.MaxInt64 {
+			return 0, fmt.Errorf("%v overflows int64", value)
+		}
+		return int64(value), nil
+	case uint64:
+		if value > math.MaxInt64 {
+			return 0, fmt.Errorf("%v overflows int64", value)
 		}
-		return int64(r), err
+		return int64(value), nil
+	case float32:
+		return floatToInt64(float64(value))
+	case float64:
+		return floatToInt64(value)
+	case nil:
+		return 0, errors.New("can't convert nil to int")
+	default:
+		return parseInt64(ToStr(value))
+	}
+}
+
+// parseInt64 parses integers and falls back to decimal for forms like "1e+06" or "3.0",
+// so large values don't lose digits through float64.
+func parseInt64(str string) (int64, error) {
+	r, err := strconv.ParseInt(str, 10, 64)
+	if err == nil {
+		return r, nil
+	}
+	d, derr := decimal.NewFromString(str)
+	if derr != nil {
+		return 0, err
+	}
+	return decimalToInt64(d)
+}
+
+func decimalToInt64(d decimal.Decimal) (int64, error) {
+	if !d.Equal(d.Truncate(0)) {
+		return 0, fmt.Errorf("%v is not an integer", d)
+	}
+	b := d.BigInt()
+	if !b.IsInt64() {
+		return 0, fmt.Errorf("%v overflows int64", d)
 	}
+	return b.Int64(), nil
+}
+
+func floatToInt64(f float64) (int64, error) {
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("%v is not an integer", f)
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which is out of range
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("%v overflows int64", f)
+	}
+	return int64(f), nil
 }
 
 func ToFloat64(value interface{}) (float64, error) {
@@ -145,6 +212,13 @@ func ToFloat64(value interface{}) (float64, error) {
 		return float64(value), nil
 	case float64:
 		return value, nil
+	case json.Number:
+		return strconv.ParseFloat(string(value), 64)
+	case decimal.Decimal:
+		f, _ := value.Float64()
+		return f, nil
+	case nil:
+		return 0, errors.New("can't convert nil to float")
 	default:
 		r, err := strconv.ParseFloat(ToStr(value), 64)
 		return r, err
@@ -169,6 +243,15 @@ func ToStr(inter interface{}) string {
 		return fmt.Sprintf("%v", value)
 	case float64:
 		return decimal.NewFromFloat(value).String()
+	case json.Number:
+		return string(value)
+	case decimal.Decimal:
+		return value.String()
+	case *decimal.Decimal:
+		if value == nil {
+			return ""
+		}
+		return value.String()
 	case *interface{}:
 		s, _ := interfaceToString(inter)
 		return s
@@ -214,25 +297,29 @@ func (jsonObject *JSONObject) GetFloat(key string) float64 {
 
 func (jsonObject *JSONObject) GetBool(key string) bool {
 	value := jsonObject.Get(key)
-	v := value
-	val, ok := v.(bool)
+	val, _ := ToBool(value)
+	return val
+}
+
+func ToBool(value interface{}) (bool, error) {
+	val, ok := value.(bool)
 	if ok {
-		return val
+		return val, nil
 	}
 
-	str := ToStr(v)
+	str := ToStr(value)
 	switch str {
 	case "1", "t", "T", "true", "TRUE", "True":
-		return true
+		return true, nil
 	case "0", "f", "F", "false", "FALSE", "False":
-		return false
+		return false, nil
 	}
-	return false
+	return false, fmt.Errorf("can't convert %v to bool", str)
 }
 
 func (jsonObject *JSONObject) Get(key string) interface{} {
-	jsonObject.lock.Lock()
-	defer jsonObject.lock.Unlock()
+	jsonObject.lock.RLock()
+	defer jsonObject.lock.RUnlock()
 	return jsonObject.ItemMap[key]
 }
 
@@ -272,17 +359,56 @@ func (jsonObject *JSONObject) GetStringArray(key string) ([]string, error) {
 	return strSlice, nil
 }
 
+// GetJSONObject returns the nested object of key. A nested map is wrapped in a new
+// JSONObject sharing the map but not the lock of the wrapper, use PromoteJSONObject to
+// change it concurrently. Other values, like a json string, are parsed into a new object.
 func (jsonObject *JSONObject) GetJSONObject(key string) (*JSONObject, error) {
-	value := jsonObject.Get(key)
-	// val, ok := value.(string)
-	// if !ok {
-	// 	Log.Fatal("convert to JSONObject error, value is {}", value)
-	// }
+	jsonObject.lock.RLock()
+	value := jsonObject.ItemMap[key]
+	switch v := value.(type) {
+	case *JSONObject:
+		jsonObject.lock.RUnlock()
+		return v, nil
+	case map[string]interface{}:
+		result := NewObject()
+		result.ItemMap = v
+		result.frozen = jsonObject.frozen
+		result.setParent(jsonObject)
+		jsonObject.lock.RUnlock()
+		return result, nil
+	}
+	jsonObject.lock.RUnlock()
 	result, err := ParseJSONObject(value)
 	return result, err
 }
 
+// PromoteJSONObject replaces the nested map of key by a *JSONObject wrapping it and returns
+// the object, so every caller getting it shares its lock and can change it concurrently.
+// After that ItemMap[key] holds the *JSONObject instead of the map. It returns an error if
+// the value of key is neither a map nor a JSONObject.
+func (jsonObject *JSONObject) PromoteJSONObject(key string) (*JSONObject, error) {
+	jsonObject.lock.Lock()
+	defer jsonObject.lock.Unlock()
+	switch v := jsonObject.ItemMap[key].(type) {
+	case *JSONObject:
+		return v, nil
+	case map[string]interface{}:
+		jsonObject.checkWritable()
+		result := NewObject()
+		result.ItemMap = v
+		result.setParent(jsonObject)
+		jsonObject.ItemMap[key] = result
+		return result, nil
+	case nil:
+		return nil, fmt.Errorf("key %s not found", key)
+	default:
+		return nil, fmt.Errorf("%s is %T, not an object", key, v)
+	}
+}
+
 func (jsonObject *JSONObject) Size() int {
+	jsonObject.lock.RLock()
+	defer jsonObject.lock.RUnlock()
 	return len(jsonObject.ItemMap)
 }
 
@@ -290,13 +416,18 @@ func ParseJSONObject(inter interface{}) (*JSONObject, error) {
 	if inter == nil {
 		return nil, errors.New("ParseJSONObject param is nil")
 	}
+	if jsonObject, ok := inter.(*JSONObject); ok {
+		// copy like other values, so changing the result doesn't change the caller's object
+		return jsonObject.Clone(), nil
+	}
 
 	kind := reflect.TypeOf(inter).Kind()
 	if kind == reflect.Map || kind == reflect.Ptr {
 		mapRes, ok := inter.(map[string]interface{})
 		if ok {
+			// copy the map so changing the object doesn't change the caller's map
 			jsonObject := NewObject()
-			jsonObject.ItemMap = mapRes
+			jsonObject.ItemMap = deepCopy(mapRes, jsonObject).(map[string]interface{})
 			return jsonObject, nil
 		} else {
 			// cast to map fail, convert by bytes. but value object is a new object, memory address will be changed
@@ -395,16 +526,21 @@ func ParseArray(str string, inter interface{}) error {
 }
 
 func (jsonObject *JSONObject) ToJSONString() string {
-	res, _ := json.Marshal(jsonObject.ItemMap)
+	res, _ := jsonObject.MarshalJSON()
 	return string(res)
 }
 
 func (jsonObject *JSONObject) ToBytes() []byte {
-	res, _ := json.Marshal(jsonObject.ItemMap)
+	res, _ := jsonObject.MarshalJSON()
 	return res
 }
 
 func (jsonObject *JSONObject) MarshalJSON() ([]byte, error) {
+	if jsonObject.ordered {
+		return jsonObject.marshalOrdered()
+	}
+	jsonObject.lock.RLock()
+	defer jsonObject.lock.RUnlock()
 	res, err := json.Marshal(jsonObject.ItemMap)
 	return res, err
 }
@@ -432,6 +568,8 @@ func (jsonObject *JSONObject) String() string {
 }
 
 func (jsonObject *JSONObject) IsNull() bool {
+	jsonObject.lock.RLock()
+	defer jsonObject.lock.RUnlock()
 	return jsonObject.ItemMap == nil
 }
 
@@ -439,10 +577,10 @@ func IsEmpty(s string) bool {
 	return len(s) == 0
 }
 
+// Each iterates over a snapshot of the members, so f may modify the object.
 func (jsonObject *JSONObject) Each(f func(key string, val interface{})) {
-	jsonObject.lock.Lock()
-	defer jsonObject.lock.Unlock()
-	for key, val := range jsonObject.ItemMap {
-		f(key, val)
+	keys, items := snapshotItems(jsonObject)
+	for _, key := range keys {
+		f(key, items[key])
 	}
 }
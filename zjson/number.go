@@ -0,0 +1,107 @@
+package zjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+)
+
+// ParsePrecise parses data like ParseBytes but keeps numbers as json.Number, so 64 bit
+// ids and decimal amounts don't lose precision through float64. GetInt64, GetFloat,
+// GetDecimal and GetString read them losslessly.
+func ParsePrecise(data []byte) (*JSONObject, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	jsonObject := NewObject()
+	err := decoder.Decode(&jsonObject.ItemMap)
+	if err != nil {
+		return nil, err
+	}
+	if jsonObject.ItemMap == nil {
+		return nil, errors.New("ParsePrecise data is not a json object")
+	}
+	return jsonObject, nil
+}
+
+// ParsePreciseArray parses a json array keeping numbers as json.Number.
+func ParsePreciseArray(data []byte) ([]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	arr := make([]interface{}, 0)
+	err := decoder.Decode(&arr)
+	if err != nil {
+		return nil, err
+	}
+	return arr, nil
+}
+
+func ToDecimal(value interface{}) (decimal.Decimal, error) {
+	switch value := value.(type) {
+	case decimal.Decimal:
+		return value, nil
+	case *decimal.Decimal:
+		if value == nil {
+			return decimal.Zero, errors.New("can't convert nil to decimal")
+		}
+		return *value, nil
+	case json.Number:
+		return decimal.NewFromString(string(value))
+	case string:
+		return decimal.NewFromString(value)
+	case int:
+		return decimal.NewFromInt(int64(value)), nil
+	case int64:
+		return decimal.NewFromInt(value), nil
+	case int32:
+		return decimal.NewFromInt32(value), nil
+	case uint64:
+		return decimal.NewFromBigInt(new(big.Int).SetUint64(value), 0), nil
+	case float32:
+		return decimal.NewFromFloat32(value), nil
+	case float64:
+		return decimal.NewFromFloat(value), nil
+	case nil:
+		return decimal.Zero, errors.New("can't convert nil to decimal")
+	}
+	d, err := decimal.NewFromString(ToStr(value))
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("can't convert %v to decimal", value)
+	}
+	return d, nil
+}
+
+// GetDecimal returns the value of key as a decimal, numbers parsed by ParsePrecise
+// keep all their digits.
+func (jsonObject *JSONObject) GetDecimal(key string) (decimal.Decimal, error) {
+	value, err := jsonObject.GetE(key)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	res, err := ToDecimal(value)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("key %s: %v", key, err)
+	}
+	return res, nil
+}
+
+// GetNumber returns the value of key as a json.Number, integers and decimals keep their
+// digits, floats are formatted like ToStr.
+func (jsonObject *JSONObject) GetNumber(key string) (json.Number, error) {
+	value, err := jsonObject.GetE(key)
+	if err != nil {
+		return "", err
+	}
+	if n, ok := value.(json.Number); ok {
+		return n, nil
+	}
+	str := ToStr(value)
+	if _, err = strconv.ParseFloat(str, 64); err != nil {
+		return "", fmt.Errorf("key %s: %s is not a number", key, str)
+	}
+	return json.Number(str), nil
+}
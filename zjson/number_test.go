@@ -0,0 +1,190 @@
+package zjson
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+const preciseDoc = `{"id":9007199254740993,"big":123456789012345678901234567890,"amount":0.1,"exp":1e+06,
+	"round":9007199254740993.0,"frac":1.5,"neg":-9223372036854775808,"s":"x","n":null}`
+
+func TestParsePrecise(t *testing.T) {
+	obj, err := ParsePrecise([]byte(preciseDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	int64Tests := []struct {
+		key  string
+		want int64
+		ok   bool
+	}{
+		{"id", 9007199254740993, true},
+		{"exp", 1000000, true},
+		{"round", 9007199254740993, true},
+		{"neg", -9223372036854775808, true},
+		{"big", 0, false},
+		{"frac", 0, false},
+		{"s", 0, false},
+		{"n", 0, false},
+		{"missing", 0, false},
+	}
+	for _, test := range int64Tests {
+		got, err := obj.GetInt64E(test.key)
+		if test.ok && (err != nil || got != test.want) {
+			t.Errorf("GetInt64E(%s) = %d, %v, want %d", test.key, got, err, test.want)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("GetInt64E(%s) = %d, want an error", test.key, got)
+		}
+	}
+	stringTests := []struct {
+		key  string
+		want string
+	}{
+		{"id", "9007199254740993"},
+		{"big", "123456789012345678901234567890"},
+		{"amount", "0.1"},
+		{"exp", "1e+06"},
+	}
+	for _, test := range stringTests {
+		if got := obj.GetString(test.key); got != test.want {
+			t.Errorf("GetString(%s) = %s, want %s", test.key, got, test.want)
+		}
+	}
+	if got := obj.GetFloat("amount"); got != 0.1 {
+		t.Errorf("GetFloat(amount) = %v, want 0.1", got)
+	}
+	if got := obj.ToJSONString(); got != `{"amount":0.1,"big":123456789012345678901234567890,"exp":1e+06,"frac":1.5,"id":9007199254740993,"n":null,"neg":-9223372036854775808,"round":9007199254740993.0,"s":"x"}` {
+		t.Errorf("ToJSONString = %s, numbers must keep their digits", got)
+	}
+}
+
+func TestParsePreciseErrors(t *testing.T) {
+	for _, data := range []string{`[1]`, `null`, `{`, `1`} {
+		if _, err := ParsePrecise([]byte(data)); err == nil {
+			t.Errorf("ParsePrecise(%s) returned no error", data)
+		}
+	}
+	if _, err := ParsePreciseArray([]byte(`{}`)); err == nil {
+		t.Error("ParsePreciseArray of an object returned no error")
+	}
+}
+
+func TestParsePreciseArray(t *testing.T) {
+	arr, err := ParsePreciseArray([]byte(`[9007199254740993,{"a":0.1}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arr[0] != json.Number("9007199254740993") {
+		t.Errorf("element 0 = %#v, want json.Number", arr[0])
+	}
+	if obj, ok := arr[1].(map[string]interface{}); !ok || obj["a"] != json.Number("0.1") {
+		t.Errorf("element 1 = %#v, want a nested json.Number", arr[1])
+	}
+}
+
+func TestGetDecimal(t *testing.T) {
+	obj, err := ParsePrecise([]byte(preciseDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.Put("float", 0.1)
+	obj.Put("int", 7)
+	obj.Put("str", "2.50")
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"big", "123456789012345678901234567890", true},
+		{"amount", "0.1", true},
+		{"exp", "1000000", true},
+		{"float", "0.1", true},
+		{"int", "7", true},
+		{"str", "2.5", true},
+		{"s", "", false},
+		{"n", "", false},
+		{"missing", "", false},
+	}
+	for _, test := range tests {
+		got, err := obj.GetDecimal(test.key)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("GetDecimal(%s) = %s, want an error", test.key, got)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(decimal.RequireFromString(test.want)) {
+			t.Errorf("GetDecimal(%s) = %s, %v, want %s", test.key, got, err, test.want)
+		}
+	}
+}
+
+func TestToDecimal(t *testing.T) {
+	d := decimal.RequireFromString("1.25")
+	var nilDecimal *decimal.Decimal
+	tests := []struct {
+		value interface{}
+		want  string
+		ok    bool
+	}{
+		{d, "1.25", true},
+		{&d, "1.25", true},
+		{json.Number("1.25"), "1.25", true},
+		{"1.25", "1.25", true},
+		{int32(-3), "-3", true},
+		{int64(9007199254740993), "9007199254740993", true},
+		{uint64(18446744073709551615), "18446744073709551615", true},
+		{float32(0.5), "0.5", true},
+		{int8(4), "4", true},
+		{nil, "", false},
+		{nilDecimal, "", false},
+		{"abc", "", false},
+		{true, "", false},
+	}
+	for _, test := range tests {
+		got, err := ToDecimal(test.value)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("ToDecimal(%#v) = %s, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(decimal.RequireFromString(test.want)) {
+			t.Errorf("ToDecimal(%#v) = %s, %v, want %s", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestGetNumber(t *testing.T) {
+	obj, err := ParsePrecise([]byte(preciseDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.Put("float", 0.1)
+	obj.Put("int64", int64(9007199254740993))
+	obj.Put("numeric", "12")
+	tests := []struct {
+		key  string
+		want json.Number
+		ok   bool
+	}{
+		{"id", "9007199254740993", true},
+		{"float", "0.1", true},
+		{"int64", "9007199254740993", true},
+		{"numeric", "12", true},
+		{"s", "", false},
+		{"missing", "", false},
+	}
+	for _, test := range tests {
+		got, err := obj.GetNumber(test.key)
+		if test.ok && (err != nil || got != test.want) {
+			t.Errorf("GetNumber(%s) = %s, %v, want %s", test.key, got, err, test.want)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("GetNumber(%s) = %s, want an error", test.key, got)
+		}
+	}
+}
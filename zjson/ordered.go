@@ -0,0 +1,207 @@
+package zjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync/atomic"
+)
+
+// NewOrderedObject returns an object which remembers the insertion order of its keys
+// and marshals them in that order instead of sorting them.
+func NewOrderedObject() *JSONObject {
+	newObj := NewObject()
+	newObj.ordered = true
+	return newObj
+}
+
+// ParseOrdered parses data into an ordered object keeping the order of the keys, nested
+// objects are ordered objects too and numbers are kept as json.Number. Until the object
+// or one of its nested objects is changed through JSONObject methods, ToBytes and
+// ToJSONString return data unchanged, so a signature over the raw json still matches.
+// Values changed directly on ItemMap or on nested slices are not tracked, keys added
+// directly on ItemMap are marshaled after the tracked ones in sorted order.
+func ParseOrdered(data []byte) (*JSONObject, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, errors.New("ParseOrdered data is not a json object")
+	}
+	value, err := parseOrderedValue(trimmed, nil)
+	if err != nil {
+		return nil, err
+	}
+	jsonObject := value.(*JSONObject)
+	jsonObject.raw = append([]byte(nil), data...)
+	return jsonObject, nil
+}
+
+func parseOrderedValue(data []byte, parent *JSONObject) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	switch data[0] {
+	case '{':
+		jsonObject := NewOrderedObject()
+		jsonObject.setParent(parent)
+		jsonObject.raw = data
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := token.(string)
+			var raw json.RawMessage
+			if err = decoder.Decode(&raw); err != nil {
+				return nil, err
+			}
+			value, err := parseOrderedValue(raw, jsonObject)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := jsonObject.ItemMap[key]; !ok {
+				jsonObject.keys = append(jsonObject.keys, key)
+			}
+			jsonObject.ItemMap[key] = value
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return jsonObject, nil
+	case '[':
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, 0)
+		for decoder.More() {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return nil, err
+			}
+			value, err := parseOrderedValue(raw, parent)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	default:
+		var value interface{}
+		err := decoder.Decode(&value)
+		return value, err
+	}
+}
+
+// IsOrdered returns true for objects created by NewOrderedObject or ParseOrdered.
+func (jsonObject *JSONObject) IsOrdered() bool {
+	return jsonObject.ordered
+}
+
+// Keys returns the keys in insertion order for an ordered object, otherwise sorted.
+func (jsonObject *JSONObject) Keys() []string {
+	jsonObject.lock.RLock()
+	defer jsonObject.lock.RUnlock()
+	if jsonObject.ordered {
+		keys, _ := jsonObject.orderedKeys()
+		return keys
+	}
+	keys := make([]string, 0, len(jsonObject.ItemMap))
+	for key := range jsonObject.ItemMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// touchKey records key and marks the object changed, the caller must hold the lock.
+func (jsonObject *JSONObject) touchKey(key string) {
+	jsonObject.checkWritable()
+	if jsonObject.ordered {
+		if _, ok := jsonObject.ItemMap[key]; !ok {
+			jsonObject.keys = append(jsonObject.keys, key)
+		}
+	}
+	jsonObject.markModified()
+}
+
+// removeKey forgets key and marks the object changed, the caller must hold the lock.
+func (jsonObject *JSONObject) removeKey(key string) {
+	jsonObject.checkWritable()
+	jsonObject.markModified()
+	if !jsonObject.ordered {
+		return
+	}
+	for idx, k := range jsonObject.keys {
+		if k == key {
+			jsonObject.keys = append(jsonObject.keys[:idx], jsonObject.keys[idx+1:]...)
+			break
+		}
+	}
+}
+
+// orderedKeys returns the keys of an ordered object in insertion order followed by the
+// sorted keys set directly on ItemMap, keys removed directly from ItemMap are skipped.
+// tracked is false if ItemMap was changed that way. The caller must hold the lock.
+func (jsonObject *JSONObject) orderedKeys() (keys []string, tracked bool) {
+	keys = make([]string, 0, len(jsonObject.ItemMap))
+	seen := make(map[string]bool, len(jsonObject.keys))
+	for _, key := range jsonObject.keys {
+		if _, ok := jsonObject.ItemMap[key]; ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	tracked = len(keys) == len(jsonObject.keys)
+	if len(keys) == len(jsonObject.ItemMap) {
+		return keys, tracked
+	}
+	var untracked []string
+	for key := range jsonObject.ItemMap {
+		if !seen[key] {
+			untracked = append(untracked, key)
+		}
+	}
+	sort.Strings(untracked)
+	return append(keys, untracked...), false
+}
+
+// markModified invalidates the raw json and the snapshot of the object and of the objects
+// containing it. It only uses atomics, so it doesn't need the locks of the parents.
+func (jsonObject *JSONObject) markModified() {
+	atomic.StoreInt32(&jsonObject.modified, 1)
+	atomic.AddInt64(&jsonObject.version, 1)
+	for _, parent := range jsonObject.getParents() {
+		parent.markModified()
+	}
+}
+
+func (jsonObject *JSONObject) marshalOrdered() ([]byte, error) {
+	jsonObject.lock.RLock()
+	defer jsonObject.lock.RUnlock()
+	keys, tracked := jsonObject.orderedKeys()
+	if tracked && jsonObject.raw != nil && atomic.LoadInt32(&jsonObject.modified) == 0 {
+		return jsonObject.raw, nil
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, _ := json.Marshal(key)
+		buf.Write(b)
+		buf.WriteByte(':')
+		b, err := json.Marshal(jsonObject.ItemMap[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,39 @@
+package zjson
+
+import "testing"
+
+func TestOrderedKeepsUntrackedKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(obj *JSONObject)
+		want   string
+	}{
+		{"unchanged", func(obj *JSONObject) {}, `{"b":1, "a":2}`},
+		{"added on ItemMap", func(obj *JSONObject) {
+			obj.ItemMap["d"] = 4
+			obj.ItemMap["c"] = 3
+		}, `{"b":1,"a":2,"c":3,"d":4}`},
+		{"removed on ItemMap", func(obj *JSONObject) {
+			delete(obj.ItemMap, "b")
+		}, `{"a":2}`},
+		{"added by Put after ItemMap", func(obj *JSONObject) {
+			obj.ItemMap["d"] = 4
+			obj.Put("c", 3)
+		}, `{"b":1,"a":2,"c":3,"d":4}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj, err := ParseOrdered([]byte(`{"b":1, "a":2}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.change(obj)
+			if got := obj.ToJSONString(); got != test.want {
+				t.Errorf("ToJSONString = %s, want %s", got, test.want)
+			}
+			if got := obj.Clone().ToJSONString(); got != test.want {
+				t.Errorf("Clone().ToJSONString = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+package zjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type MergeStrategy int
+
+const (
+	// MergeReplaceArrays replaces an array with the array of the other object
+	MergeReplaceArrays MergeStrategy = iota
+	// MergeAppendArrays appends the elements of the other array
+	MergeAppendArrays
+)
+
+// PatchOp is an RFC 6902 JSON Patch operation.
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON writes value for add, replace and test even when it is null.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{"op": op.Op, "path": op.Path}
+	switch op.Op {
+	case "add", "replace", "test":
+		m["value"] = op.Value
+	case "move", "copy":
+		m["from"] = op.From
+	}
+	return json.Marshal(m)
+}
+
+// ParsePatch parses an RFC 6902 JSON Patch document.
+func ParsePatch(data []byte) ([]PatchOp, error) {
+	patch := make([]PatchOp, 0)
+	err := json.Unmarshal(data, &patch)
+	if err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+func snapshotItems(jsonObject *JSONObject) ([]string, map[string]interface{}) {
+	jsonObject.lock.RLock()
+	defer jsonObject.lock.RUnlock()
+	var keys []string
+	if jsonObject.ordered {
+		keys, _ = jsonObject.orderedKeys()
+	} else {
+		keys = make([]string, 0, len(jsonObject.ItemMap))
+		for key := range jsonObject.ItemMap {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+	items := make(map[string]interface{}, len(jsonObject.ItemMap))
+	for key, val := range jsonObject.ItemMap {
+		items[key] = val
+	}
+	return keys, items
+}
+
+// Merge deep merges other into the object: nested objects are merged, arrays are replaced
+// or appended according to strategy and other values are replaced. Values taken from
+// other are copied, so changing other later doesn't change the object.
+func (jsonObject *JSONObject) Merge(other *JSONObject, strategy MergeStrategy) {
+	if other == nil || other == jsonObject {
+		return
+	}
+	keys, items := snapshotItems(other)
+	jsonObject.lock.Lock()
+	defer jsonObject.lock.Unlock()
+	for _, key := range keys {
+		jsonObject.setItem(key, mergeValue(jsonObject.ItemMap[key], items[key], strategy, jsonObject))
+	}
+}
+
+func mergeValue(dst interface{}, src interface{}, strategy MergeStrategy, parent *JSONObject) interface{} {
+	switch d := dst.(type) {
+	case *JSONObject:
+		if s, ok := src.(*JSONObject); ok {
+			d.Merge(s, strategy)
+			return d
+		}
+		if s, ok := src.(map[string]interface{}); ok {
+			d.Merge(&JSONObject{ItemMap: s}, strategy)
+			return d
+		}
+	case map[string]interface{}:
+		var items map[string]interface{}
+		if s, ok := src.(*JSONObject); ok {
+			_, items = snapshotItems(s)
+		} else if s, ok := src.(map[string]interface{}); ok {
+			items = s
+		}
+		if items != nil {
+			for key, val := range items {
+				old := d[key]
+				d[key] = mergeValue(old, val, strategy, parent)
+				parent.adoptReplaced(d[key], old)
+			}
+			return d
+		}
+	case []interface{}:
+		if s, ok := src.([]interface{}); ok && strategy == MergeAppendArrays {
+			res := make([]interface{}, 0, len(d)+len(s))
+			res = append(res, d...)
+			return append(res, deepCopy(s, parent).([]interface{})...)
+		}
+	}
+	return deepCopy(src, parent)
+}
+
+// MergePatch applies an RFC 7396 merge patch: null values remove keys, objects are
+// merged recursively and other values replace the existing ones.
+func (jsonObject *JSONObject) MergePatch(patch *JSONObject) {
+	if patch == nil || patch == jsonObject {
+		return
+	}
+	keys, items := snapshotItems(patch)
+	jsonObject.lock.Lock()
+	defer jsonObject.lock.Unlock()
+	for _, key := range keys {
+		val := items[key]
+		if val == nil {
+			jsonObject.deleteItem(key)
+			continue
+		}
+		jsonObject.setItem(key, mergePatchValue(jsonObject.ItemMap[key], val, jsonObject))
+	}
+}
+
+func mergePatchValue(target interface{}, patch interface{}, parent *JSONObject) interface{} {
+	var patchObj *JSONObject
+	switch p := patch.(type) {
+	case *JSONObject:
+		patchObj = p
+	case map[string]interface{}:
+		patchObj = &JSONObject{ItemMap: p}
+	default:
+		return deepCopy(patch, parent)
+	}
+	switch t := target.(type) {
+	case *JSONObject:
+		t.MergePatch(patchObj)
+		return t
+	case map[string]interface{}:
+		// the wrapper passes the writes below it on to parent
+		obj := &JSONObject{ItemMap: t}
+		obj.setParent(parent)
+		obj.MergePatch(patchObj)
+		return t
+	}
+	var obj *JSONObject
+	if parent != nil && parent.ordered {
+		obj = NewOrderedObject()
+		obj.setParent(parent)
+	} else {
+		obj = NewObject()
+	}
+	obj.MergePatch(patchObj)
+	if obj.ordered {
+		return obj
+	}
+	return obj.ItemMap
+}
+
+// MergePatchDiff returns the RFC 7396 merge patch turning a into b.
+func MergePatchDiff(a *JSONObject, b *JSONObject) *JSONObject {
+	aKeys, aItems := snapshotItems(a)
+	bKeys, bItems := snapshotItems(b)
+	patch := NewObject()
+	for _, key := range aKeys {
+		if _, ok := bItems[key]; !ok {
+			patch.Put(key, nil)
+		}
+	}
+	for _, key := range bKeys {
+		aVal, ok := aItems[key]
+		bVal := bItems[key]
+		aObj, aok := asObject(aVal)
+		bObj, bok := asObject(bVal)
+		if ok && aok && bok {
+			sub := MergePatchDiff(aObj, bObj)
+			if sub.Size() > 0 {
+				patch.Put(key, sub)
+			}
+			continue
+		}
+		if !ok || !valuesEqual(aVal, bVal) {
+			patch.Put(key, deepCopy(bVal, nil))
+		}
+	}
+	return patch
+}
+
+func asObject(value interface{}) (*JSONObject, bool) {
+	switch v := value.(type) {
+	case *JSONObject:
+		return v, true
+	case map[string]interface{}:
+		return &JSONObject{ItemMap: v}, true
+	}
+	return nil, false
+}
+
+// Diff returns the RFC 6902 JSON Patch operations turning a into b.
+func Diff(a *JSONObject, b *JSONObject) []PatchOp {
+	patch := make([]PatchOp, 0)
+	return diffValue(a, b, "", patch)
+}
+
+func diffValue(a interface{}, b interface{}, path string, patch []PatchOp) []PatchOp {
+	aObj, aok := asObject(a)
+	bObj, bok := asObject(b)
+	if aok && bok {
+		aKeys, aItems := snapshotItems(aObj)
+		bKeys, bItems := snapshotItems(bObj)
+		for _, key := range aKeys {
+			if _, ok := bItems[key]; !ok {
+				patch = append(patch, PatchOp{Op: "remove", Path: path + "/" + escapePointer(key)})
+			}
+		}
+		for _, key := range bKeys {
+			keyPath := path + "/" + escapePointer(key)
+			aVal, ok := aItems[key]
+			if !ok {
+				patch = append(patch, PatchOp{Op: "add", Path: keyPath, Value: deepCopy(bItems[key], nil)})
+				continue
+			}
+			patch = diffValue(aVal, bItems[key], keyPath, patch)
+		}
+		return patch
+	}
+	aArr, aok := a.([]interface{})
+	bArr, bok := b.([]interface{})
+	if aok && bok {
+		common := len(aArr)
+		if len(bArr) < common {
+			common = len(bArr)
+		}
+		for i := 0; i < common; i++ {
+			patch = diffValue(aArr[i], bArr[i], path+"/"+strconv.Itoa(i), patch)
+		}
+		for i := common; i < len(bArr); i++ {
+			patch = append(patch, PatchOp{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: deepCopy(bArr[i], nil)})
+		}
+		// remove from the end so the indexes stay valid
+		for i := len(aArr) - 1; i >= common; i-- {
+			patch = append(patch, PatchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		return patch
+	}
+	if !valuesEqual(a, b) {
+		patch = append(patch, PatchOp{Op: "replace", Path: path, Value: deepCopy(b, nil)})
+	}
+	return patch
+}
+
+// ApplyPatch applies RFC 6902 JSON Patch operations. The operations are applied to a copy,
+// so if one of them fails the object is left unchanged.
+func (jsonObject *JSONObject) ApplyPatch(patch []PatchOp) error {
+	jsonObject.lock.Lock()
+	defer jsonObject.lock.Unlock()
+	if jsonObject.frozen {
+		return errReadOnly
+	}
+	root := jsonObject.copyLocked(nil)
+	var doc interface{} = root
+	for i, op := range patch {
+		var err error
+		doc, err = applyOp(doc, op)
+		if err != nil {
+			return fmt.Errorf("patch operation %d (%s %s): %v", i, op.Op, op.Path, err)
+		}
+	}
+	result, ok := asObject(doc)
+	if !ok {
+		return errors.New("patch result is not an object")
+	}
+	if jsonObject.ordered && !result.ordered {
+		result.keys, _ = snapshotItems(result)
+	}
+	for _, val := range result.ItemMap {
+		reparent(val, result, jsonObject)
+	}
+	old := jsonObject.ItemMap
+	jsonObject.ItemMap = result.ItemMap
+	// the result holds copies, the objects it replaced are no longer below the object
+	jsonObject.release(old)
+	jsonObject.keys = result.keys
+	jsonObject.markModified()
+	return nil
+}
+
+// reparent moves the objects directly below from to to.
+func reparent(value interface{}, from *JSONObject, to *JSONObject) {
+	switch v := value.(type) {
+	case *JSONObject:
+		v.replaceParent(from, to)
+	case []interface{}:
+		for _, val := range v {
+			reparent(val, from, to)
+		}
+	case map[string]interface{}:
+		for _, val := range v {
+			reparent(val, from, to)
+		}
+	}
+}
+
+func applyOp(doc interface{}, op PatchOp) (interface{}, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add":
+		return addAt(doc, tokens, deepCopy(op.Value, nil))
+	case "remove":
+		if len(tokens) == 0 {
+			return nil, errors.New("can't remove the root")
+		}
+		return updateAt(doc, tokens, func(container interface{}, key string) (interface{}, error) {
+			return removeChild(container, key)
+		})
+	case "replace":
+		if _, err = getAt(doc, tokens); err != nil {
+			return nil, err
+		}
+		if len(tokens) == 0 {
+			return deepCopy(op.Value, nil), nil
+		}
+		return updateAt(doc, tokens, func(container interface{}, key string) (interface{}, error) {
+			return setChild(container, key, deepCopy(op.Value, nil), false)
+		})
+	case "move", "copy":
+		fromTokens, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		val, err := getAt(doc, fromTokens)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return addAt(doc, tokens, deepCopy(val, nil))
+		}
+		if op.Path == op.From {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, errors.New("can't move a value into itself")
+		}
+		if len(fromTokens) == 0 {
+			return nil, errors.New("can't move the root")
+		}
+		doc, err = updateAt(doc, fromTokens, func(container interface{}, key string) (interface{}, error) {
+			return removeChild(container, key)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return addAt(doc, tokens, val)
+	case "test":
+		val, err := getAt(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !valuesEqual(val, op.Value) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %s", op.Op)
+}
+
+func addAt(doc interface{}, tokens []string, val interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return val, nil
+	}
+	return updateAt(doc, tokens, func(container interface{}, key string) (interface{}, error) {
+		return setChild(container, key, val, true)
+	})
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %s", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func getAt(doc interface{}, tokens []string) (interface{}, error) {
+	node := doc
+	for _, token := range tokens {
+		child, err := getChild(node, token)
+		if err != nil {
+			return nil, err
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// updateAt calls f with the container of the last token and writes the container
+// returned by f back into its parent.
+func updateAt(node interface{}, tokens []string, f func(container interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return f(node, tokens[0])
+	}
+	child, err := getChild(node, tokens[0])
+	if err != nil {
+		return nil, err
+	}
+	newChild, err := updateAt(child, tokens[1:], f)
+	if err != nil {
+		return nil, err
+	}
+	return setChild(node, tokens[0], newChild, false)
+}
+
+func arrayIndex(token string, size int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return size, nil
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %s", token)
+	}
+	if idx > size || (!allowEnd && idx == size) {
+		return 0, fmt.Errorf("array index %d out of range", idx)
+	}
+	return idx, nil
+}
+
+func getChild(node interface{}, token string) (interface{}, error) {
+	switch v := node.(type) {
+	case *JSONObject:
+		val, err := v.GetE(token)
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
+	case map[string]interface{}:
+		val, ok := v[token]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found", token)
+		}
+		return val, nil
+	case []interface{}:
+		idx, err := arrayIndex(token, len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		return v[idx], nil
+	}
+	return nil, fmt.Errorf("can't get %s from %T", token, node)
+}
+
+// setChild sets key of container, insert adds an element to an array instead of replacing it.
+func setChild(container interface{}, key string, val interface{}, insert bool) (interface{}, error) {
+	switch v := container.(type) {
+	case *JSONObject:
+		if obj, ok := val.(*JSONObject); ok && v.ordered {
+			obj.setParent(v)
+		}
+		v.Put(key, val)
+		return v, nil
+	case map[string]interface{}:
+		v[key] = val
+		return v, nil
+	case []interface{}:
+		idx, err := arrayIndex(key, len(v), insert)
+		if err != nil {
+			return nil, err
+		}
+		if !insert {
+			v[idx] = val
+			return v, nil
+		}
+		res := make([]interface{}, 0, len(v)+1)
+		res = append(res, v[:idx]...)
+		res = append(res, val)
+		return append(res, v[idx:]...), nil
+	}
+	return nil, fmt.Errorf("can't set %s on %T", key, container)
+}
+
+func removeChild(container interface{}, key string) (interface{}, error) {
+	switch v := container.(type) {
+	case *JSONObject:
+		if !v.Contains(key) {
+			return nil, fmt.Errorf("key %s not found", key)
+		}
+		v.Remove(key)
+		return v, nil
+	case map[string]interface{}:
+		if _, ok := v[key]; !ok {
+			return nil, fmt.Errorf("key %s not found", key)
+		}
+		delete(v, key)
+		return v, nil
+	case []interface{}:
+		idx, err := arrayIndex(key, len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]interface{}, 0, len(v)-1)
+		res = append(res, v[:idx]...)
+		return append(res, v[idx+1:]...), nil
+	}
+	return nil, fmt.Errorf("can't remove %s from %T", key, container)
+}
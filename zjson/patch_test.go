@@ -0,0 +1,226 @@
+package zjson
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) *JSONObject {
+	t.Helper()
+	obj, err := ParseJSONObject(s)
+	if err != nil {
+		t.Fatalf("parse %s: %v", s, err)
+	}
+	return obj
+}
+
+func mustParsePatch(t *testing.T, s string) []PatchOp {
+	t.Helper()
+	patch, err := ParsePatch([]byte(s))
+	if err != nil {
+		t.Fatalf("parse patch %s: %v", s, err)
+	}
+	return patch
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"add", `{"a":1}`, `[{"op":"add","path":"/b","value":{"c":[1]}}]`, `{"a":1,"b":{"c":[1]}}`},
+		{"add replaces", `{"a":1}`, `[{"op":"add","path":"/a","value":2}]`, `{"a":2}`},
+		{"add null", `{}`, `[{"op":"add","path":"/a","value":null}]`, `{"a":null}`},
+		{"add array insert", `{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2,3]}`},
+		{"add array end", `{"a":[1,2]}`, `[{"op":"add","path":"/a/-","value":3}]`, `{"a":[1,2,3]}`},
+		{"add nested array end", `{"a":{"b":[]}}`, `[{"op":"add","path":"/a/b/-","value":{"c":1}}]`, `{"a":{"b":[{"c":1}]}}`},
+		{"escaped key", `{}`, `[{"op":"add","path":"/a~1b~0c","value":1}]`, `{"a/b~c":1}`},
+		{"remove", `{"a":1,"b":2}`, `[{"op":"remove","path":"/a"}]`, `{"b":2}`},
+		{"remove array element", `{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/1"}]`, `{"a":[1,3]}`},
+		{"replace", `{"a":{"b":1}}`, `[{"op":"replace","path":"/a/b","value":"x"}]`, `{"a":{"b":"x"}}`},
+		{"move", `{"a":{"b":1},"c":{}}`, `[{"op":"move","from":"/a/b","path":"/c/d"}]`, `{"a":{},"c":{"d":1}}`},
+		{"move to array end", `{"a":[1,2],"b":3}`, `[{"op":"move","from":"/b","path":"/a/-"}]`, `{"a":[1,2,3]}`},
+		{"move to itself", `{"a":1}`, `[{"op":"move","from":"/a","path":"/a"}]`, `{"a":1}`},
+		{"copy", `{"a":{"b":[1]}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":[1]},"c":{"b":[1]}}`},
+		{"test", `{"a":[1,{"b":"x"}]}`, `[{"op":"test","path":"/a","value":[1,{"b":"x"}]},{"op":"add","path":"/c","value":1}]`,
+			`{"a":[1,{"b":"x"}],"c":1}`},
+		{"test number types", `{"a":1}`, `[{"op":"test","path":"/a","value":1.0}]`, `{"a":1}`},
+		{"replace root", `{"a":1}`, `[{"op":"replace","path":"","value":{"b":2}}]`, `{"b":2}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := mustParse(t, test.doc)
+			if err := obj.ApplyPatch(mustParsePatch(t, test.patch)); err != nil {
+				t.Fatal(err)
+			}
+			if want := mustParse(t, test.want); !valuesEqual(obj, want) {
+				t.Errorf("result = %s, want %s", obj.ToJSONString(), test.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{"test failed", `[{"op":"test","path":"/a","value":2}]`},
+		{"test missing", `[{"op":"test","path":"/x","value":1}]`},
+		{"remove missing", `[{"op":"remove","path":"/x"}]`},
+		{"remove root", `[{"op":"remove","path":""}]`},
+		{"replace missing", `[{"op":"replace","path":"/x","value":1}]`},
+		{"add missing parent", `[{"op":"add","path":"/x/y","value":1}]`},
+		{"array index out of range", `[{"op":"add","path":"/b/5","value":1}]`},
+		{"array index not a number", `[{"op":"add","path":"/b/x","value":1}]`},
+		{"remove array end", `[{"op":"remove","path":"/b/-"}]`},
+		{"move into itself", `[{"op":"move","from":"/c","path":"/c/d"}]`},
+		{"move missing", `[{"op":"move","from":"/x","path":"/y"}]`},
+		{"copy missing", `[{"op":"copy","from":"/x","path":"/y"}]`},
+		{"bad pointer", `[{"op":"add","path":"a","value":1}]`},
+		{"unknown op", `[{"op":"swap","path":"/a"}]`},
+		{"later op fails", `[{"op":"add","path":"/z","value":1},{"op":"test","path":"/a","value":2}]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := `{"a":1,"b":[1,2],"c":{"d":1}}`
+			obj := mustParse(t, doc)
+			if err := obj.ApplyPatch(mustParsePatch(t, test.patch)); err == nil {
+				t.Fatal("ApplyPatch returned no error")
+			}
+			if want := mustParse(t, doc); !valuesEqual(obj, want) {
+				t.Errorf("failed patch changed the object to %s", obj.ToJSONString())
+			}
+		})
+	}
+}
+
+func TestDiffApplyPatchRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+	}{
+		{"equal", `{"a":1,"b":[1,2]}`, `{"a":1,"b":[1,2]}`},
+		{"add remove replace", `{"a":1,"b":"x","c":true}`, `{"a":2,"c":true,"d":null}`},
+		{"nested", `{"a":{"b":{"c":1,"d":2}}}`, `{"a":{"b":{"c":3,"e":[1]}}}`},
+		{"array grow", `{"a":[1,2]}`, `{"a":[1,3,4,{"b":1}]}`},
+		{"array shrink", `{"a":[1,2,3,4]}`, `{"a":[0]}`},
+		{"type change", `{"a":{"b":1},"c":[1]}`, `{"a":[1],"c":{"d":1}}`},
+		{"escaped keys", `{"a/b":1,"c~d":2}`, `{"a/b":2}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := mustParse(t, test.a), mustParse(t, test.b)
+			patch := Diff(a, b)
+			if test.a == test.b && len(patch) != 0 {
+				t.Errorf("Diff of equal objects = %v, want no operation", patch)
+			}
+			// the patch goes through json like a patch sent to another service
+			data, err := json.Marshal(patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := a.ApplyPatch(mustParsePatch(t, string(data))); err != nil {
+				t.Fatalf("ApplyPatch(%s): %v", data, err)
+			}
+			if !valuesEqual(a, b) {
+				t.Errorf("ApplyPatch(%s) = %s, want %s", data, a.ToJSONString(), test.b)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		dst      string
+		src      string
+		strategy MergeStrategy
+		want     string
+	}{
+		{"add and replace", `{"a":1,"b":2}`, `{"b":3,"c":4}`, MergeReplaceArrays, `{"a":1,"b":3,"c":4}`},
+		{"deep", `{"a":{"b":1,"c":{"d":1}}}`, `{"a":{"c":{"e":2}}}`, MergeReplaceArrays, `{"a":{"b":1,"c":{"d":1,"e":2}}}`},
+		{"replace arrays", `{"a":[1,2]}`, `{"a":[3]}`, MergeReplaceArrays, `{"a":[3]}`},
+		{"append arrays", `{"a":[1,2]}`, `{"a":[3]}`, MergeAppendArrays, `{"a":[1,2,3]}`},
+		{"object replaces value", `{"a":1}`, `{"a":{"b":1}}`, MergeReplaceArrays, `{"a":{"b":1}}`},
+		{"value replaces object", `{"a":{"b":1}}`, `{"a":1}`, MergeReplaceArrays, `{"a":1}`},
+		{"null is kept", `{"a":1}`, `{"a":null}`, MergeReplaceArrays, `{"a":null}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dst, src := mustParse(t, test.dst), mustParse(t, test.src)
+			dst.Merge(src, test.strategy)
+			if want := mustParse(t, test.want); !valuesEqual(dst, want) {
+				t.Errorf("Merge = %s, want %s", dst.ToJSONString(), test.want)
+			}
+		})
+	}
+}
+
+func TestMergeCopiesOther(t *testing.T) {
+	dst := mustParse(t, `{"a":{"b":1}}`)
+	src := mustParse(t, `{"a":{"c":[1]},"d":{"e":1}}`)
+	dst.Merge(src, MergeReplaceArrays)
+	if err := src.SetPath("d.e", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetPath("a.c[0]", 2); err != nil {
+		t.Fatal(err)
+	}
+	if want := mustParse(t, `{"a":{"b":1,"c":[1]},"d":{"e":1}}`); !valuesEqual(dst, want) {
+		t.Errorf("changing other after Merge changed the object to %s", dst.ToJSONString())
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// cases from RFC 7396 appendix A
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{`{"a":{"b":1}}`, `{"a":{"b":null}}`, `{"a":{}}`},
+		{`{"a":1}`, `{"x":null}`, `{"a":1}`},
+	}
+	for _, test := range tests {
+		t.Run(test.target+" "+test.patch, func(t *testing.T) {
+			target := mustParse(t, test.target)
+			target.MergePatch(mustParse(t, test.patch))
+			if want := mustParse(t, test.want); !valuesEqual(target, want) {
+				t.Errorf("MergePatch = %s, want %s", target.ToJSONString(), test.want)
+			}
+		})
+	}
+}
+
+func TestMergePatchDiff(t *testing.T) {
+	tests := []struct {
+		a string
+		b string
+	}{
+		{`{"a":1,"b":{"c":1,"d":2}}`, `{"a":1,"b":{"c":2},"e":[1]}`},
+		{`{"a":{"b":1}}`, `{"a":2}`},
+		{`{"a":[1,2]}`, `{}`},
+	}
+	for _, test := range tests {
+		a, b := mustParse(t, test.a), mustParse(t, test.b)
+		patch := MergePatchDiff(a, b)
+		a.MergePatch(patch)
+		if !valuesEqual(a, b) {
+			t.Errorf("MergePatch(MergePatchDiff(%s, %s)) = %s", test.a, test.b, a.ToJSONString())
+		}
+	}
+}
@@ -0,0 +1,622 @@
+package zjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	segKey = iota
+	segIndex
+	segWildcard
+	segRecursive
+	segFilter
+)
+
+type pathSegment struct {
+	kind   int
+	key    string
+	index  int
+	filter []filterCond
+}
+
+// filterCond is one condition of a filter like ?(@.price>10 && @.stock), join is the
+// operator before it, && binds tighter than ||.
+type filterCond struct {
+	join    string
+	path    []pathSegment
+	op      string
+	literal interface{}
+}
+
+// GetPath returns the value at a path like "data.items[0].name" or "$.data['key.with.dot']",
+// a negative index counts from the end of an array.
+func (jsonObject *JSONObject) GetPath(path string) (interface{}, error) {
+	segs, err := parsePath(path, false)
+	if err != nil {
+		return nil, err
+	}
+	var node interface{} = jsonObject
+	for _, seg := range segs {
+		var ok bool
+		if seg.kind == segKey {
+			node, ok = childByKey(node, seg.key)
+		} else {
+			node, ok = childByIndex(node, seg.index)
+		}
+		if !ok {
+			return nil, fmt.Errorf("path %s not found", path)
+		}
+	}
+	return node, nil
+}
+
+// SetPath sets the value at a path, missing objects and arrays on the way are created.
+// Setting an index past the end of an array grows it, filling the gap with nil.
+func (jsonObject *JSONObject) SetPath(path string, val interface{}) error {
+	segs, err := parsePath(path, false)
+	if err != nil {
+		return err
+	}
+	if len(segs) == 0 || segs[0].kind != segKey {
+		return fmt.Errorf("path %s must start with a key", path)
+	}
+	_, err = setIn(jsonObject, segs, val, nil)
+	return err
+}
+
+// Query returns every value matching a JSONPath expression, supporting $, .key, ['key'],
+// [n], [*], .*, ..key, ..* and filters such as $.items[?(@.price>10 && @.type=='book')].id.
+// Filters combine conditions with && and ||, && binds tighter. Object members are visited
+// in key order.
+func (jsonObject *JSONObject) Query(expr string) ([]interface{}, error) {
+	segs, err := parsePath(expr, true)
+	if err != nil {
+		return nil, err
+	}
+	return evalPath([]interface{}{jsonObject}, segs), nil
+}
+
+func evalPath(nodes []interface{}, segs []pathSegment) []interface{} {
+	for _, seg := range segs {
+		next := make([]interface{}, 0)
+		for _, node := range nodes {
+			switch seg.kind {
+			case segKey:
+				if child, ok := childByKey(node, seg.key); ok {
+					next = append(next, child)
+				}
+			case segIndex:
+				if child, ok := childByIndex(node, seg.index); ok {
+					next = append(next, child)
+				}
+			case segWildcard:
+				next = append(next, childrenOf(node)...)
+			case segRecursive:
+				for _, desc := range descendants(node) {
+					if seg.key == "*" {
+						next = append(next, childrenOf(desc)...)
+					} else if child, ok := childByKey(desc, seg.key); ok {
+						next = append(next, child)
+					}
+				}
+			case segFilter:
+				for _, child := range childrenOf(node) {
+					if matchFilter(child, seg.filter) {
+						next = append(next, child)
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descendants returns node itself and all nested values below it.
+func descendants(node interface{}) []interface{} {
+	res := []interface{}{node}
+	for _, child := range childrenOf(node) {
+		res = append(res, descendants(child)...)
+	}
+	return res
+}
+
+type arrayGetter interface {
+	GetArray() []interface{}
+}
+
+func childByKey(node interface{}, key string) (interface{}, bool) {
+	switch value := node.(type) {
+	case *JSONObject:
+		value.lock.RLock()
+		defer value.lock.RUnlock()
+		child, ok := value.ItemMap[key]
+		return child, ok
+	case map[string]interface{}:
+		child, ok := value[key]
+		return child, ok
+	case map[string]string:
+		child, ok := value[key]
+		return child, ok
+	case nil, []interface{}, arrayGetter, string:
+		return nil, false
+	}
+	if converted, ok := convertNode(node); ok {
+		return childByKey(converted, key)
+	}
+	return nil, false
+}
+
+func childByIndex(node interface{}, idx int) (interface{}, bool) {
+	var arr []interface{}
+	switch value := node.(type) {
+	case []interface{}:
+		arr = value
+	case arrayGetter:
+		arr = value.GetArray()
+	case nil, *JSONObject, map[string]interface{}, map[string]string, string:
+		return nil, false
+	default:
+		if converted, ok := convertNode(node); ok {
+			return childByIndex(converted, idx)
+		}
+		return nil, false
+	}
+	if idx < 0 {
+		idx += len(arr)
+	}
+	if idx < 0 || idx >= len(arr) {
+		return nil, false
+	}
+	return arr[idx], true
+}
+
+func childrenOf(node interface{}) []interface{} {
+	switch value := node.(type) {
+	case []interface{}:
+		return value
+	case arrayGetter:
+		return value.GetArray()
+	case *JSONObject:
+		value.lock.RLock()
+		defer value.lock.RUnlock()
+		if value.ordered {
+			keys, _ := value.orderedKeys()
+			res := make([]interface{}, len(keys))
+			for i, key := range keys {
+				res[i] = value.ItemMap[key]
+			}
+			return res
+		}
+		return mapValues(value.ItemMap)
+	case map[string]interface{}:
+		return mapValues(value)
+	case map[string]string:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		res := make([]interface{}, len(keys))
+		for i, key := range keys {
+			res[i] = value[key]
+		}
+		return res
+	case nil, string:
+		return nil
+	}
+	if converted, ok := convertNode(node); ok {
+		return childrenOf(converted)
+	}
+	return nil
+}
+
+func mapValues(m map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	res := make([]interface{}, len(keys))
+	for i, key := range keys {
+		res[i] = m[key]
+	}
+	return res
+}
+
+// convertNode converts other maps, slices and structs through json so they can be walked.
+func convertNode(node interface{}) (interface{}, bool) {
+	kind := reflect.TypeOf(node).Kind()
+	if kind == reflect.Ptr {
+		kind = reflect.TypeOf(node).Elem().Kind()
+	}
+	if kind != reflect.Map && kind != reflect.Slice && kind != reflect.Array && kind != reflect.Struct {
+		return nil, false
+	}
+	b, err := json.Marshal(node)
+	if err != nil {
+		return nil, false
+	}
+	var res interface{}
+	if json.Unmarshal(b, &res) != nil {
+		return nil, false
+	}
+	return res, true
+}
+
+// setIn sets val below node and returns node, or the new container if node had to be
+// created or grown. Objects created below an ordered parent are ordered too.
+func setIn(node interface{}, segs []pathSegment, val interface{}, parent *JSONObject) (interface{}, error) {
+	if len(segs) == 0 {
+		return val, nil
+	}
+	seg := segs[0]
+	if seg.kind == segKey {
+		switch value := node.(type) {
+		case *JSONObject:
+			value.lock.Lock()
+			defer value.lock.Unlock()
+			if value.frozen {
+				return nil, errReadOnly
+			}
+			if value.ItemMap == nil {
+				value.ItemMap = make(map[string]interface{})
+			}
+			child, err := setIn(value.ItemMap[seg.key], segs[1:], val, value)
+			if err != nil {
+				return nil, err
+			}
+			value.setItem(seg.key, child)
+			return value, nil
+		case map[string]interface{}:
+			child, err := setIn(value[seg.key], segs[1:], val, parent)
+			if err != nil {
+				return nil, err
+			}
+			old := value[seg.key]
+			value[seg.key] = child
+			parent.adoptReplaced(child, old)
+			return value, nil
+		case nil:
+			if parent != nil && parent.ordered {
+				obj := NewOrderedObject()
+				obj.setParent(parent)
+				return setIn(obj, segs, val, parent)
+			}
+			return setIn(make(map[string]interface{}), segs, val, parent)
+		default:
+			return nil, fmt.Errorf("can't set key %s on %T", seg.key, node)
+		}
+	}
+	var arr []interface{}
+	switch value := node.(type) {
+	case []interface{}:
+		arr = value
+	case nil:
+		arr = make([]interface{}, 0)
+	default:
+		return nil, fmt.Errorf("can't set index %d on %T", seg.index, node)
+	}
+	idx := seg.index
+	if idx < 0 {
+		idx += len(arr)
+		if idx < 0 {
+			return nil, fmt.Errorf("index %d out of range", seg.index)
+		}
+	}
+	for len(arr) <= idx {
+		arr = append(arr, nil)
+	}
+	child, err := setIn(arr[idx], segs[1:], val, parent)
+	if err != nil {
+		return nil, err
+	}
+	old := arr[idx]
+	arr[idx] = child
+	parent.adoptReplaced(child, old)
+	return arr, nil
+}
+
+func parsePath(path string, query bool) ([]pathSegment, error) {
+	segs := make([]pathSegment, 0)
+	i := 0
+	if strings.HasPrefix(path, "$") {
+		i = 1
+	} else if len(path) > 0 && path[0] != '.' && path[0] != '[' {
+		// a path without $ may start with a bare key
+		path = "." + path
+	}
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			if strings.HasPrefix(path[i:], "..") {
+				if !query {
+					return nil, fmt.Errorf("recursive descent is only supported by Query: %s", path)
+				}
+				i += 2
+				name, n := readName(path[i:])
+				if name == "" {
+					return nil, fmt.Errorf("missing name after .. in %s", path)
+				}
+				segs = append(segs, pathSegment{kind: segRecursive, key: name})
+				i += n
+				continue
+			}
+			i++
+			name, n := readName(path[i:])
+			if name == "" {
+				return nil, fmt.Errorf("missing name at %d in %s", i, path)
+			}
+			if name == "*" {
+				if !query {
+					return nil, fmt.Errorf("wildcard is only supported by Query: %s", path)
+				}
+				segs = append(segs, pathSegment{kind: segWildcard})
+			} else {
+				segs = append(segs, pathSegment{kind: segKey, key: name})
+			}
+			i += n
+		case '[':
+			end := matchBracket(path, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in %s", path)
+			}
+			seg, err := parseBracket(strings.TrimSpace(path[i+1:end]), query)
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, seg)
+			i = end + 1
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d in %s", path[i], i, path)
+		}
+	}
+	return segs, nil
+}
+
+func readName(s string) (string, int) {
+	n := 0
+	for n < len(s) && s[n] != '.' && s[n] != '[' {
+		n++
+	}
+	return strings.TrimSpace(s[:n]), n
+}
+
+// matchBracket returns the index of the ] closing the [ at start, skipping quoted text.
+func matchBracket(s string, start int) int {
+	depth := 0
+	var quote byte
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseBracket(content string, query bool) (pathSegment, error) {
+	switch {
+	case content == "*":
+		if !query {
+			return pathSegment{}, errors.New("wildcard is only supported by Query")
+		}
+		return pathSegment{kind: segWildcard}, nil
+	case strings.HasPrefix(content, "?(") && strings.HasSuffix(content, ")"):
+		if !query {
+			return pathSegment{}, errors.New("filter is only supported by Query")
+		}
+		conds, err := parseFilter(content[2 : len(content)-1])
+		if err != nil {
+			return pathSegment{}, err
+		}
+		return pathSegment{kind: segFilter, filter: conds}, nil
+	case len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0]:
+		return pathSegment{kind: segKey, key: content[1 : len(content)-1]}, nil
+	}
+	idx, err := strconv.Atoi(content)
+	if err != nil {
+		return pathSegment{}, fmt.Errorf("invalid index [%s]", content)
+	}
+	return pathSegment{kind: segIndex, index: idx}, nil
+}
+
+var filterOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseFilter(expr string) ([]filterCond, error) {
+	conds := make([]filterCond, 0)
+	join := ""
+	for _, part := range splitFilter(expr) {
+		if part == "&&" || part == "||" {
+			join = part
+			continue
+		}
+		cond := filterCond{join: join}
+		left := part
+		for _, op := range filterOps {
+			if idx := indexOutsideQuotes(part, op); idx >= 0 {
+				cond.op = op
+				left = strings.TrimSpace(part[:idx])
+				literal, err := parseLiteral(strings.TrimSpace(part[idx+len(op):]))
+				if err != nil {
+					return nil, err
+				}
+				cond.literal = literal
+				break
+			}
+		}
+		if !strings.HasPrefix(left, "@") {
+			return nil, fmt.Errorf("filter condition must start with @: %s", part)
+		}
+		segs, err := parsePath(left[1:], true)
+		if err != nil {
+			return nil, err
+		}
+		cond.path = segs
+		conds = append(conds, cond)
+	}
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("empty filter ?(%s)", expr)
+	}
+	return conds, nil
+}
+
+// splitFilter splits a filter expression into conditions and && / || operators.
+func splitFilter(expr string) []string {
+	res := make([]string, 0)
+	start := 0
+	var quote byte
+	for i := 0; i < len(expr)-1; i++ {
+		c := expr[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '\'' || c == '"' {
+			quote = c
+			continue
+		}
+		if expr[i:i+2] == "&&" || expr[i:i+2] == "||" {
+			res = append(res, strings.TrimSpace(expr[start:i]), expr[i:i+2])
+			start = i + 2
+			i++
+		}
+	}
+	return append(res, strings.TrimSpace(expr[start:]))
+}
+
+func indexOutsideQuotes(s string, sub string) int {
+	var quote byte
+	for i := 0; i+len(sub) <= len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '\'' || c == '"' {
+			quote = c
+			continue
+		}
+		if s[i:i+len(sub)] == sub {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseLiteral(s string) (interface{}, error) {
+	switch {
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	case s == "null":
+		return nil, nil
+	case len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]:
+		return s[1 : len(s)-1], nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter value %s", s)
+	}
+	return f, nil
+}
+
+// matchFilter evaluates the conditions with && binding tighter than ||, so they are split
+// on || into groups and the filter matches if all conditions of any group match.
+func matchFilter(node interface{}, conds []filterCond) bool {
+	group := true
+	for i, cond := range conds {
+		if i > 0 && cond.join == "||" {
+			if group {
+				return true
+			}
+			group = true
+		}
+		group = group && matchCond(node, cond)
+	}
+	return group
+}
+
+func matchCond(node interface{}, cond filterCond) bool {
+	values := evalPath([]interface{}{node}, cond.path)
+	if len(values) == 0 {
+		return cond.op == "!="
+	}
+	value := values[0]
+	if cond.op == "" {
+		return true
+	}
+	if cond.literal == nil || value == nil {
+		equal := cond.literal == nil && value == nil
+		return (cond.op == "==" && equal) || (cond.op == "!=" && !equal)
+	}
+	var res int
+	if literal, ok := cond.literal.(float64); ok {
+		f, err := ToFloat64(value)
+		if err != nil {
+			return cond.op == "!="
+		}
+		res = compareFloat(f, literal)
+	} else if literal, ok := cond.literal.(bool); ok {
+		b, err := ToBool(value)
+		if err != nil || (cond.op != "==" && cond.op != "!=") {
+			return cond.op == "!="
+		}
+		if b == literal {
+			res = 0
+		} else {
+			res = 1
+		}
+	} else {
+		res = strings.Compare(ToStr(value), cond.literal.(string))
+	}
+	switch cond.op {
+	case "==":
+		return res == 0
+	case "!=":
+		return res != 0
+	case "<":
+		return res < 0
+	case "<=":
+		return res <= 0
+	case ">":
+		return res > 0
+	default:
+		return res >= 0
+	}
+}
+
+func compareFloat(a float64, b float64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
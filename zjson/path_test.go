@@ -0,0 +1,194 @@
+package zjson
+
+import (
+	"reflect"
+	"testing"
+)
+
+const pathDoc = `{
+	"data": {
+		"items": [
+			{"id": 1, "name": "pen", "price": 5, "type": "tool", "stock": true},
+			{"id": 2, "name": "book", "price": 12, "type": "book", "stock": false},
+			{"id": 3, "name": "lamp", "price": 30, "type": "tool"}
+		],
+		"key.with.dot": "dotted",
+		"owner": {"name": "tom"}
+	}
+}`
+
+func TestGetPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"data.items[0].name", "pen"},
+		{"$.data.items[1].price", float64(12)},
+		{"data.items[-1].name", "lamp"},
+		{"data['key.with.dot']", "dotted"},
+		{`data["owner"].name`, "tom"},
+		{"data.items[2].stock", nil},
+	}
+	obj := mustParse(t, pathDoc)
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := obj.GetPath(test.path)
+			if test.want == nil {
+				if err == nil {
+					t.Errorf("GetPath = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !valuesEqual(got, test.want) {
+				t.Errorf("GetPath = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetPathErrors(t *testing.T) {
+	obj := mustParse(t, pathDoc)
+	for _, path := range []string{"data.missing", "data.items[3]", "data.items[-4]", "data.owner[0]",
+		"data.items.name", "data.items[*]", "data.items[?(@.id)]", "data.items[x]", "data.items[0"} {
+		if got, err := obj.GetPath(path); err == nil {
+			t.Errorf("GetPath(%s) = %v, want an error", path, got)
+		}
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		path string
+		val  interface{}
+		want string
+	}{
+		{"existing key", `{"a":{"b":1}}`, "a.b", 2, `{"a":{"b":2}}`},
+		{"creates objects", `{}`, "a.b.c", 1, `{"a":{"b":{"c":1}}}`},
+		{"creates arrays", `{}`, "a[1].b", 1, `{"a":[null,{"b":1}]}`},
+		{"grows array", `{"a":[1]}`, "a[3]", 4, `{"a":[1,null,null,4]}`},
+		{"negative index", `{"a":[1,2]}`, "a[-1]", 3, `{"a":[1,3]}`},
+		{"quoted key", `{}`, "a['b.c']", 1, `{"a":{"b.c":1}}`},
+		{"nested object", `{"a":[{"b":1}]}`, "a[0].c", true, `{"a":[{"b":1,"c":true}]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := mustParse(t, test.doc)
+			if err := obj.SetPath(test.path, test.val); err != nil {
+				t.Fatal(err)
+			}
+			if want := mustParse(t, test.want); !valuesEqual(obj, want) {
+				t.Errorf("SetPath = %s, want %s", obj.ToJSONString(), test.want)
+			}
+		})
+	}
+}
+
+func TestSetPathErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"starts with index", "[0]"},
+		{"empty", ""},
+		{"key on a string", "s.x"},
+		{"index on an object", "o[0]"},
+		{"negative index out of range", "a[-3]"},
+		{"wildcard", "a[*]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := `{"s":"x","o":{},"a":[1]}`
+			obj := mustParse(t, doc)
+			if err := obj.SetPath(test.path, 1); err == nil {
+				t.Error("SetPath returned no error")
+			}
+		})
+	}
+	snapshot := mustParse(t, `{}`).Snapshot()
+	if err := snapshot.SetPath("a", 1); err != errReadOnly {
+		t.Errorf("SetPath on a snapshot = %v, want errReadOnly", err)
+	}
+}
+
+func TestSetPathOrdered(t *testing.T) {
+	obj, err := ParseOrdered([]byte(`{"z":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.SetPath("y.b", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.SetPath("y.a", 2); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := obj.ToJSONString(), `{"z":1,"y":{"b":1,"a":2}}`; got != want {
+		t.Errorf("SetPath on an ordered object = %s, want %s", got, want)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []interface{}
+	}{
+		{"$.data.items[*].id", []interface{}{1, 2, 3}},
+		{"$.data.items[?(@.price>10)].id", []interface{}{2, 3}},
+		{"$.data.items[?(@.price>=12)].name", []interface{}{"book", "lamp"}},
+		{"$.data.items[?(@.price<12)].id", []interface{}{1}},
+		{"$.data.items[?(@.type=='tool')].name", []interface{}{"pen", "lamp"}},
+		{"$.data.items[?(@.type!='tool')].name", []interface{}{"book"}},
+		{"$.data.items[?(@.stock)].id", []interface{}{1, 2}},
+		{"$.data.items[?(@.stock==true)].id", []interface{}{1}},
+		// a missing member is not null
+		{"$.data.items[?(@.stock==null)].id", []interface{}{}},
+		{"$.data.items[?(@.stock!=true)].id", []interface{}{2, 3}},
+		{"$.data.items[?(@.price>10 && @.type=='tool')].id", []interface{}{3}},
+		{"$.data.items[?(@.price<10 || @.price>20)].id", []interface{}{1, 3}},
+		{"$.data.items[?(@.id==1 || @.id==2 && @.type=='tool')].id", []interface{}{1}},
+		{"$.data.items[?(@.name=='a && b' || @.id==3)].id", []interface{}{3}},
+		{"$..name", []interface{}{"pen", "book", "lamp", "tom"}},
+		{"$.data.owner.*", []interface{}{"tom"}},
+		{"$.data.items[-1].id", []interface{}{3}},
+		{"$.data.missing", []interface{}{}},
+	}
+	obj := mustParse(t, pathDoc)
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			got, err := obj.Query(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !valuesEqual(got, test.want) {
+				t.Errorf("Query = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	obj := mustParse(t, pathDoc)
+	for _, expr := range []string{"$.data.items[?()]", "$.data.items[?(price>1)]", "$.data.items[?(@.price>x)]", "$.data[x]"} {
+		if got, err := obj.Query(expr); err == nil {
+			t.Errorf("Query(%s) = %v, want an error", expr, got)
+		}
+	}
+}
+
+func TestQueryOrderedKeepsKeyOrder(t *testing.T) {
+	obj, err := ParseOrdered([]byte(`{"b":1,"a":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := obj.Query("$.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{float64(1), float64(2)}; !reflect.DeepEqual(PlainValue(got), want) {
+		t.Errorf("Query($.*) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,545 @@
+// Package schema validates JSONObject payloads against a subset of JSON Schema draft 2020-12:
+// type, const, enum, required, properties, additionalProperties, items, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, multipleOf, minLength, maxLength, minItems, maxItems, pattern and format.
+// Numbers are compared exactly, so integers above 2^53 and decimals like 0.1 are not rounded.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wellmoon/go/zjson"
+)
+
+// Violation is a value not matching the schema, Path is a JSON pointer like /items/0/name.
+type Violation struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+func (violation Violation) String() string {
+	path := violation.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + violation.Message
+}
+
+// ValidationError is returned by Check and holds every violation.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (validationError *ValidationError) Error() string {
+	msgs := make([]string, len(validationError.Violations))
+	for i, violation := range validationError.Violations {
+		msgs[i] = violation.String()
+	}
+	return "schema validation failed: " + strings.Join(msgs, "; ")
+}
+
+type Schema struct {
+	always           *bool
+	types            []string
+	constValue       interface{}
+	hasConst         bool
+	enum             []interface{}
+	hasEnum          bool
+	required         []string
+	properties       map[string]*Schema
+	additional       *Schema
+	items            *Schema
+	minimum          *number
+	maximum          *number
+	exclusiveMinimum *number
+	exclusiveMaximum *number
+	multipleOf       *number
+	minLength        *int
+	maxLength        *int
+	minItems         *int
+	maxItems         *int
+	pattern          *regexp.Regexp
+	format           string
+}
+
+// Load compiles a schema from json bytes.
+func Load(data []byte) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw interface{}
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+	return compile(raw, "")
+}
+
+// LoadObject compiles a schema held in a JSONObject.
+func LoadObject(obj *zjson.JSONObject) (*Schema, error) {
+	if obj == nil {
+		return nil, errors.New("schema object is nil")
+	}
+	return Load(obj.ToBytes())
+}
+
+func compile(raw interface{}, path string) (*Schema, error) {
+	if b, ok := raw.(bool); ok {
+		return &Schema{always: &b}, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema at %s must be an object or a bool", pointerOrRoot(path))
+	}
+	schema := &Schema{}
+	var err error
+	for key, val := range m {
+		keyPath := path + "/" + escapePointer(key)
+		switch key {
+		case "type":
+			switch t := val.(type) {
+			case string:
+				schema.types = []string{t}
+			case []interface{}:
+				for _, e := range t {
+					s, sok := e.(string)
+					if !sok {
+						return nil, fmt.Errorf("type at %s must be a string", keyPath)
+					}
+					schema.types = append(schema.types, s)
+				}
+			default:
+				return nil, fmt.Errorf("type at %s must be a string or an array", keyPath)
+			}
+		case "const":
+			schema.constValue = val
+			schema.hasConst = true
+		case "enum":
+			arr, aok := val.([]interface{})
+			if !aok {
+				return nil, fmt.Errorf("enum at %s must be an array", keyPath)
+			}
+			for _, e := range arr {
+				schema.enum = append(schema.enum, e)
+			}
+			schema.hasEnum = true
+		case "required":
+			arr, aok := val.([]interface{})
+			if !aok {
+				return nil, fmt.Errorf("required at %s must be an array", keyPath)
+			}
+			for _, e := range arr {
+				s, sok := e.(string)
+				if !sok {
+					return nil, fmt.Errorf("required at %s must hold strings", keyPath)
+				}
+				schema.required = append(schema.required, s)
+			}
+		case "properties":
+			props, pok := val.(map[string]interface{})
+			if !pok {
+				return nil, fmt.Errorf("properties at %s must be an object", keyPath)
+			}
+			schema.properties = make(map[string]*Schema, len(props))
+			for name, prop := range props {
+				schema.properties[name], err = compile(prop, keyPath+"/"+escapePointer(name))
+				if err != nil {
+					return nil, err
+				}
+			}
+		case "additionalProperties":
+			if schema.additional, err = compile(val, keyPath); err != nil {
+				return nil, err
+			}
+		case "items":
+			if schema.items, err = compile(val, keyPath); err != nil {
+				return nil, err
+			}
+		case "minimum":
+			schema.minimum, err = toNumber(val, keyPath)
+		case "maximum":
+			schema.maximum, err = toNumber(val, keyPath)
+		case "exclusiveMinimum":
+			schema.exclusiveMinimum, err = toNumber(val, keyPath)
+		case "exclusiveMaximum":
+			schema.exclusiveMaximum, err = toNumber(val, keyPath)
+		case "multipleOf":
+			schema.multipleOf, err = toNumber(val, keyPath)
+			if err == nil && schema.multipleOf.rat.Sign() <= 0 {
+				err = fmt.Errorf("%s must be greater than 0", keyPath)
+			}
+		case "minLength":
+			schema.minLength, err = toInt(val, keyPath)
+		case "maxLength":
+			schema.maxLength, err = toInt(val, keyPath)
+		case "minItems":
+			schema.minItems, err = toInt(val, keyPath)
+		case "maxItems":
+			schema.maxItems, err = toInt(val, keyPath)
+		case "pattern":
+			s, sok := val.(string)
+			if !sok {
+				return nil, fmt.Errorf("pattern at %s must be a string", keyPath)
+			}
+			if schema.pattern, err = regexp.Compile(s); err != nil {
+				return nil, fmt.Errorf("pattern at %s: %v", keyPath, err)
+			}
+		case "format":
+			schema.format, _ = val.(string)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return schema, nil
+}
+
+// number is a json number kept exactly, text is the number as written in the schema.
+type number struct {
+	rat  *big.Rat
+	text string
+}
+
+func toNumber(val interface{}, path string) (*number, error) {
+	n, ok := val.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a number", path)
+	}
+	rat, ok := toRat(n)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a number", path)
+	}
+	return &number{rat, n.String()}, nil
+}
+
+func toRat(n json.Number) (*big.Rat, bool) {
+	return new(big.Rat).SetString(n.String())
+}
+
+func toInt(val interface{}, path string) (*int, error) {
+	i, err := zjson.ToInt(val)
+	if err != nil || i < 0 {
+		return nil, fmt.Errorf("%s must be a non negative integer", path)
+	}
+	return &i, nil
+}
+
+// Validate returns every violation of obj, or nil if obj matches the schema.
+func (schema *Schema) Validate(obj *zjson.JSONObject) []Violation {
+	var value interface{}
+	if obj != nil {
+		// a json round trip turns nested objects, Go integers and structs into plain values
+		// and keeps every number exact as a json.Number
+		data, err := obj.MarshalJSON()
+		if err == nil {
+			value, err = decode(data)
+		}
+		if err != nil {
+			return []Violation{{"", "type", err.Error()}}
+		}
+	}
+	violations := make([]Violation, 0)
+	schema.validate(value, "", &violations)
+	if len(violations) == 0 {
+		return nil
+	}
+	return violations
+}
+
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	err := decoder.Decode(&value)
+	return value, err
+}
+
+// ValidateBytes validates raw json, err is only set when data is not valid json.
+func (schema *Schema) ValidateBytes(data []byte) ([]Violation, error) {
+	value, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	violations := make([]Violation, 0)
+	schema.validate(value, "", &violations)
+	if len(violations) == 0 {
+		return nil, nil
+	}
+	return violations, nil
+}
+
+// Check returns a *ValidationError holding every violation, or nil.
+func (schema *Schema) Check(obj *zjson.JSONObject) error {
+	violations := schema.Validate(obj)
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: violations}
+}
+
+func (schema *Schema) validate(value interface{}, path string, violations *[]Violation) {
+	fail := func(keyword string, format string, args ...interface{}) {
+		*violations = append(*violations, Violation{path, keyword, fmt.Sprintf(format, args...)})
+	}
+	if schema.always != nil {
+		if !*schema.always {
+			fail("false", "no value is allowed")
+		}
+		return
+	}
+	if len(schema.types) > 0 {
+		matched := false
+		for _, t := range schema.types {
+			if matchType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("type", "expected %s but got %s", strings.Join(schema.types, " or "), typeName(value))
+			// the other keywords would only repeat the type error
+			return
+		}
+	}
+	if schema.hasConst && !equal(value, schema.constValue) {
+		fail("const", "must be %s", zjson.ToJSONString(schema.constValue))
+	}
+	if schema.hasEnum {
+		found := false
+		for _, e := range schema.enum {
+			if equal(value, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("enum", "must be one of %s", zjson.ToJSONString(schema.enum))
+		}
+	}
+	switch value := value.(type) {
+	case json.Number:
+		rat, ok := toRat(value)
+		if !ok {
+			fail("type", "%s is not a number", value)
+			break
+		}
+		if schema.minimum != nil && rat.Cmp(schema.minimum.rat) < 0 {
+			fail("minimum", "must be >= %s", schema.minimum.text)
+		}
+		if schema.maximum != nil && rat.Cmp(schema.maximum.rat) > 0 {
+			fail("maximum", "must be <= %s", schema.maximum.text)
+		}
+		if schema.exclusiveMinimum != nil && rat.Cmp(schema.exclusiveMinimum.rat) <= 0 {
+			fail("exclusiveMinimum", "must be > %s", schema.exclusiveMinimum.text)
+		}
+		if schema.exclusiveMaximum != nil && rat.Cmp(schema.exclusiveMaximum.rat) >= 0 {
+			fail("exclusiveMaximum", "must be < %s", schema.exclusiveMaximum.text)
+		}
+		if schema.multipleOf != nil && !new(big.Rat).Quo(rat, schema.multipleOf.rat).IsInt() {
+			fail("multipleOf", "must be a multiple of %s", schema.multipleOf.text)
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if schema.minLength != nil && length < *schema.minLength {
+			fail("minLength", "length must be >= %d", *schema.minLength)
+		}
+		if schema.maxLength != nil && length > *schema.maxLength {
+			fail("maxLength", "length must be <= %d", *schema.maxLength)
+		}
+		if schema.pattern != nil && !schema.pattern.MatchString(value) {
+			fail("pattern", "must match %s", schema.pattern.String())
+		}
+		if schema.format != "" && !matchFormat(value, schema.format) {
+			fail("format", "must be a valid %s", schema.format)
+		}
+	case []interface{}:
+		if schema.minItems != nil && len(value) < *schema.minItems {
+			fail("minItems", "must have >= %d items", *schema.minItems)
+		}
+		if schema.maxItems != nil && len(value) > *schema.maxItems {
+			fail("maxItems", "must have <= %d items", *schema.maxItems)
+		}
+		if schema.items != nil {
+			for i, item := range value {
+				schema.items.validate(item, path+"/"+strconv.Itoa(i), violations)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.required {
+			if _, ok := value[name]; !ok {
+				*violations = append(*violations, Violation{path + "/" + escapePointer(name), "required", "is required"})
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prop, ok := schema.properties[key]
+			if !ok {
+				prop = schema.additional
+				if prop != nil && prop.always != nil && !*prop.always {
+					*violations = append(*violations, Violation{path + "/" + escapePointer(key), "additionalProperties", "is not allowed"})
+					continue
+				}
+			}
+			if prop != nil {
+				prop.validate(value[key], path+"/"+escapePointer(key), violations)
+			}
+		}
+	}
+}
+
+func matchType(value interface{}, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		rat, ok := toRat(n)
+		return ok && rat.IsInt()
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+var (
+	uuidRegexp     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hostnameRegexp = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+)
+
+// matchFormat checks the formats known by this package, unknown formats always match.
+func matchFormat(value string, format string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "time":
+		_, err := time.Parse("15:04:05Z07:00", value)
+		if err != nil {
+			_, err = time.Parse("15:04:05.999999999Z07:00", value)
+		}
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != ""
+	case "ipv4":
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+	case "ipv6":
+		ip := net.ParseIP(value)
+		return ip != nil && strings.Contains(value, ":")
+	case "uuid":
+		return uuidRegexp.MatchString(value)
+	case "hostname":
+		return len(value) <= 253 && hostnameRegexp.MatchString(value)
+	}
+	return true
+}
+
+// equal compares values decoded with UseNumber, numbers are equal if their values are,
+// so 1 equals 1.0.
+func equal(a interface{}, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		bn, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		ra, aok := toRat(a)
+		rb, bok := toRat(bn)
+		if !aok || !bok {
+			return a == bn
+		}
+		return ra.Cmp(rb) == 0
+	case []interface{}:
+		bs, ok := b.([]interface{})
+		if !ok || len(a) != len(bs) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], bs[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bm, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(bm) {
+			return false
+		}
+		for key, val := range a {
+			other, ok := bm[key]
+			if !ok || !equal(val, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func pointerOrRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,187 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wellmoon/go/zjson"
+)
+
+func TestValidateKeywords(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		data   string
+		want   []Violation
+	}{
+		{"true schema", `true`, `{"a":1}`, nil},
+		{"false schema", `false`, `{}`, []Violation{{"", "false", "no value is allowed"}}},
+		{"type", `{"type":"object"}`, `{}`, nil},
+		{"type mismatch", `{"properties":{"a":{"type":"string"}}}`, `{"a":1}`,
+			[]Violation{{"/a", "type", "expected string but got number"}}},
+		{"type list", `{"properties":{"a":{"type":["string","null"]}}}`, `{"a":null}`, nil},
+		{"integer", `{"properties":{"a":{"type":"integer"}}}`, `{"a":1.0}`, nil},
+		{"not integer", `{"properties":{"a":{"type":"integer"}}}`, `{"a":1.5}`,
+			[]Violation{{"/a", "type", "expected integer but got number"}}},
+		{"const", `{"properties":{"a":{"const":{"b":[1,"x"]}}}}`, `{"a":{"b":[1.0,"x"]}}`, nil},
+		{"const mismatch", `{"properties":{"a":{"const":1}}}`, `{"a":2}`,
+			[]Violation{{"/a", "const", "must be 1"}}},
+		{"enum", `{"properties":{"a":{"enum":["x",2]}}}`, `{"a":2}`, nil},
+		{"enum mismatch", `{"properties":{"a":{"enum":["x",2]}}}`, `{"a":"y"}`,
+			[]Violation{{"/a", "enum", `must be one of ["x",2]`}}},
+		{"required", `{"required":["a","b/c"]}`, `{"a":1}`,
+			[]Violation{{"/b~1c", "required", "is required"}}},
+		{"additionalProperties false", `{"properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":2}`,
+			[]Violation{{"/b", "additionalProperties", "is not allowed"}}},
+		{"additionalProperties schema", `{"additionalProperties":{"type":"string"}}`, `{"a":"x","b":2}`,
+			[]Violation{{"/b", "type", "expected string but got number"}}},
+		{"items", `{"properties":{"a":{"items":{"type":"integer"}}}}`, `{"a":[1,"x",3]}`,
+			[]Violation{{"/a/1", "type", "expected integer but got string"}}},
+		{"minItems maxItems", `{"properties":{"a":{"minItems":2},"b":{"maxItems":1}}}`, `{"a":[1],"b":[1,2]}`,
+			[]Violation{{"/a", "minItems", "must have >= 2 items"}, {"/b", "maxItems", "must have <= 1 items"}}},
+		{"minimum maximum", `{"properties":{"a":{"minimum":1},"b":{"maximum":1.5}}}`, `{"a":0.5,"b":2}`,
+			[]Violation{{"/a", "minimum", "must be >= 1"}, {"/b", "maximum", "must be <= 1.5"}}},
+		{"bounds inclusive", `{"properties":{"a":{"minimum":1,"maximum":1}}}`, `{"a":1}`, nil},
+		{"exclusive bounds", `{"properties":{"a":{"exclusiveMinimum":1},"b":{"exclusiveMaximum":1}}}`, `{"a":1,"b":1}`,
+			[]Violation{{"/a", "exclusiveMinimum", "must be > 1"}, {"/b", "exclusiveMaximum", "must be < 1"}}},
+		{"multipleOf", `{"properties":{"a":{"multipleOf":0.1},"b":{"multipleOf":3}}}`, `{"a":0.3,"b":9}`, nil},
+		{"not multipleOf", `{"properties":{"a":{"multipleOf":0.1},"b":{"multipleOf":3}}}`, `{"a":0.35,"b":10}`,
+			[]Violation{{"/a", "multipleOf", "must be a multiple of 0.1"}, {"/b", "multipleOf", "must be a multiple of 3"}}},
+		{"maximum above 2^53", `{"properties":{"a":{"maximum":9007199254740992}}}`, `{"a":9007199254740993}`,
+			[]Violation{{"/a", "maximum", "must be <= 9007199254740992"}}},
+		{"minimum above 2^53", `{"properties":{"a":{"minimum":9007199254740993}}}`, `{"a":9007199254740992}`,
+			[]Violation{{"/a", "minimum", "must be >= 9007199254740993"}}},
+		{"multipleOf above 2^53", `{"properties":{"a":{"multipleOf":2}}}`, `{"a":9007199254740993}`,
+			[]Violation{{"/a", "multipleOf", "must be a multiple of 2"}}},
+		{"const above 2^53", `{"properties":{"a":{"const":9007199254740993}}}`, `{"a":9007199254740992}`,
+			[]Violation{{"/a", "const", "must be 9007199254740993"}}},
+		{"minLength maxLength", `{"properties":{"a":{"minLength":3},"b":{"maxLength":2}}}`, `{"a":"中文","b":"中文"}`,
+			[]Violation{{"/a", "minLength", "length must be >= 3"}}},
+		{"pattern", `{"properties":{"a":{"pattern":"^[a-z]+$"}}}`, `{"a":"abc1"}`,
+			[]Violation{{"/a", "pattern", "must match ^[a-z]+$"}}},
+		{"nested path", `{"properties":{"a":{"properties":{"b":{"type":"string"}}}}}`, `{"a":{"b":true}}`,
+			[]Violation{{"/a/b", "type", "expected string but got boolean"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema, err := Load([]byte(test.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := schema.ValidateBytes([]byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ValidateBytes = %v, want %v", got, test.want)
+			}
+			// ParseOrdered keeps numbers as json.Number, ParseJSONObject would round them
+			obj, err := zjson.ParseOrdered([]byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schema.Validate(obj); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Validate = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		valid  string
+		bad    string
+	}{
+		{"date-time", "2024-01-02T03:04:05Z", "2024-01-02 03:04:05"},
+		{"date", "2024-01-02", "2024-13-02"},
+		{"time", "03:04:05.123+08:00", "3pm"},
+		{"email", "a@b.com", "Tom <a@b.com>"},
+		{"uri", "https://example.com/a", "/relative"},
+		{"ipv4", "10.0.0.1", "::1"},
+		{"ipv6", "::1", "10.0.0.1"},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", "123e4567"},
+		{"hostname", "a.example.com", "-a.com"},
+		{"unknown", "anything", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			schema, err := Load([]byte(`{"additionalProperties":{"format":"` + test.format + `"}}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := schema.ValidateBytes([]byte(`{"a":"` + test.valid + `"}`)); got != nil {
+				t.Errorf("%q: %v, want no violation", test.valid, got)
+			}
+			if test.bad == "" {
+				return
+			}
+			if got, _ := schema.ValidateBytes([]byte(`{"a":"` + test.bad + `"}`)); len(got) != 1 || got[0].Keyword != "format" {
+				t.Errorf("%q: %v, want a format violation", test.bad, got)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"invalid json", `{`},
+		{"not an object", `1`},
+		{"type", `{"type":1}`},
+		{"type list", `{"type":["string",1]}`},
+		{"enum", `{"enum":1}`},
+		{"required", `{"required":"a"}`},
+		{"required strings", `{"required":[1]}`},
+		{"properties", `{"properties":[]}`},
+		{"property schema", `{"properties":{"a":1}}`},
+		{"additionalProperties", `{"additionalProperties":"x"}`},
+		{"items", `{"items":1}`},
+		{"minimum", `{"minimum":"1"}`},
+		{"multipleOf zero", `{"multipleOf":0}`},
+		{"multipleOf negative", `{"multipleOf":-2}`},
+		{"minLength negative", `{"minLength":-1}`},
+		{"maxItems", `{"maxItems":"x"}`},
+		{"pattern", `{"pattern":"("}`},
+		{"pattern string", `{"pattern":1}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Load([]byte(test.schema)); err == nil {
+				t.Errorf("Load(%s) returned no error", test.schema)
+			}
+		})
+	}
+	if _, err := LoadObject(nil); err == nil {
+		t.Error("LoadObject(nil) returned no error")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	schema, err := Load([]byte(`{"required":["a"],"properties":{"b":{"maximum":9007199254740992}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := zjson.NewObject()
+	obj.Put("b", int64(9007199254740993))
+	err = schema.Check(obj)
+	var validationError *ValidationError
+	if !errors.As(err, &validationError) || len(validationError.Violations) != 2 {
+		t.Fatalf("Check = %v, want 2 violations", err)
+	}
+	want := "schema validation failed: /a: is required; /b: must be <= 9007199254740992"
+	if err.Error() != want {
+		t.Errorf("Error = %q, want %q", err.Error(), want)
+	}
+	obj.Put("a", 1)
+	obj.Put("b", int64(9007199254740992))
+	if err := schema.Check(obj); err != nil {
+		t.Errorf("Check = %v, want nil", err)
+	}
+	if _, err := schema.ValidateBytes([]byte(`{`)); err == nil {
+		t.Error("ValidateBytes of invalid json returned no error")
+	}
+}
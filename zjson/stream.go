@@ -0,0 +1,235 @@
+package zjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ArrayReader reads the elements of a json array one by one from an io.Reader, so only
+// the current element is held in memory.
+//
+// sample
+//
+//	reader := zjson.NewArrayReader(file, "data.items")
+//	for {
+//	    obj, err := reader.Next()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    ...
+//	}
+type ArrayReader struct {
+	decoder *json.Decoder
+	path    string
+	started bool
+	done    bool
+	count   int
+}
+
+// NewArrayReader reads the top level array if path is empty, otherwise the array
+// at a path like "data.items" or "pages[2].rows".
+func NewArrayReader(r io.Reader, path string) *ArrayReader {
+	return &ArrayReader{decoder: json.NewDecoder(r), path: path}
+}
+
+// UseNumber keeps numbers as json.Number instead of float64, it must be called before Next.
+func (reader *ArrayReader) UseNumber() *ArrayReader {
+	reader.decoder.UseNumber()
+	return reader
+}
+
+// Next returns the next element as a JSONObject, or io.EOF after the last element.
+func (reader *ArrayReader) Next() (*JSONObject, error) {
+	value, err := reader.NextValue()
+	if err != nil {
+		return nil, err
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("array element %d is not an object", reader.count-1)
+	}
+	jsonObject := NewObject()
+	jsonObject.ItemMap = m
+	return jsonObject, nil
+}
+
+// NextValue returns the next element whatever its type, or io.EOF after the last element.
+func (reader *ArrayReader) NextValue() (interface{}, error) {
+	if !reader.started {
+		reader.started = true
+		if err := reader.seek(); err != nil {
+			reader.done = true
+			return nil, err
+		}
+	}
+	if reader.done {
+		return nil, io.EOF
+	}
+	if !reader.decoder.More() {
+		reader.done = true
+		if _, err := reader.decoder.Token(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	var value interface{}
+	if err := reader.decoder.Decode(&value); err != nil {
+		reader.done = true
+		return nil, err
+	}
+	reader.count++
+	return value, nil
+}
+
+// Each calls f for every element, an error returned by f stops the iteration.
+func (reader *ArrayReader) Each(f func(idx int, obj *JSONObject) error) error {
+	for {
+		obj, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = f(reader.count-1, obj); err != nil {
+			return err
+		}
+	}
+}
+
+func (reader *ArrayReader) seek() error {
+	segs, err := parsePath(reader.path, false)
+	if err != nil {
+		return err
+	}
+	for _, seg := range segs {
+		token, err := reader.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if seg.kind == segKey {
+			if token != json.Delim('{') {
+				return fmt.Errorf("%s: expected an object", reader.path)
+			}
+			found := false
+			for reader.decoder.More() {
+				key, err := reader.decoder.Token()
+				if err != nil {
+					return err
+				}
+				if key == seg.key {
+					found = true
+					break
+				}
+				if err = reader.skipValue(); err != nil {
+					return err
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s: key %s not found", reader.path, seg.key)
+			}
+			continue
+		}
+		if token != json.Delim('[') {
+			return fmt.Errorf("%s: expected an array", reader.path)
+		}
+		for i := 0; i < seg.index; i++ {
+			if !reader.decoder.More() {
+				return fmt.Errorf("%s: index %d out of range", reader.path, seg.index)
+			}
+			if err = reader.skipValue(); err != nil {
+				return err
+			}
+		}
+		if seg.index < 0 || !reader.decoder.More() {
+			return fmt.Errorf("%s: index %d out of range", reader.path, seg.index)
+		}
+	}
+	token, err := reader.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != json.Delim('[') {
+		return errors.New("ArrayReader: value is not an array")
+	}
+	return nil
+}
+
+// skipValue consumes the next value token by token without decoding it.
+func (reader *ArrayReader) skipValue() error {
+	depth := 0
+	for {
+		token, err := reader.decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// ArrayWriter writes a json array element by element to an io.Writer.
+type ArrayWriter struct {
+	writer *bufio.Writer
+	count  int
+	closed bool
+}
+
+func NewArrayWriter(w io.Writer) *ArrayWriter {
+	return &ArrayWriter{writer: bufio.NewWriter(w)}
+}
+
+// Write marshals v as the next element of the array.
+func (arrayWriter *ArrayWriter) Write(v interface{}) error {
+	if arrayWriter.closed {
+		return errors.New("ArrayWriter is closed")
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if arrayWriter.count == 0 {
+		err = arrayWriter.writer.WriteByte('[')
+	} else {
+		err = arrayWriter.writer.WriteByte(',')
+	}
+	if err != nil {
+		return err
+	}
+	arrayWriter.count++
+	_, err = arrayWriter.writer.Write(b)
+	return err
+}
+
+// Flush writes buffered elements to the underlying writer.
+func (arrayWriter *ArrayWriter) Flush() error {
+	return arrayWriter.writer.Flush()
+}
+
+// Close ends the array and flushes, it doesn't close the underlying writer.
+func (arrayWriter *ArrayWriter) Close() error {
+	if arrayWriter.closed {
+		return nil
+	}
+	arrayWriter.closed = true
+	var err error
+	if arrayWriter.count == 0 {
+		_, err = arrayWriter.writer.WriteString("[]")
+	} else {
+		err = arrayWriter.writer.WriteByte(']')
+	}
+	if err != nil {
+		return err
+	}
+	return arrayWriter.writer.Flush()
+}
@@ -0,0 +1,225 @@
+package zjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readAll returns the elements of reader as plain values.
+func readAll(reader *ArrayReader) ([]interface{}, error) {
+	res := make([]interface{}, 0)
+	for {
+		value, err := reader.NextValue()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		res = append(res, value)
+	}
+}
+
+func TestArrayReader(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		path string
+		want string
+	}{
+		{"top level", `[{"a":1},{"a":2}]`, "", `[{"a":1},{"a":2}]`},
+		{"empty", ` [ ] `, "", `[]`},
+		{"scalars", `[1,"x",null,true,[1]]`, "", `[1,"x",null,true,[1]]`},
+		{"path", `{"skip":{"x":[1,{"y":2}]},"data":{"items":[{"id":1}]}}`, "data.items", `[{"id":1}]`},
+		{"path with index", `{"pages":[[9],{"rows":[0]},{"rows":[1,2]}]}`, "pages[2].rows", `[1,2]`},
+		{"quoted key", `{"a.b":[1]}`, "['a.b']", `[1]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readAll(NewArrayReader(strings.NewReader(test.data), test.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want interface{}
+			json.Unmarshal([]byte(test.want), &want)
+			if !valuesEqual(got, want) {
+				t.Errorf("elements = %v, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestArrayReaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		path string
+	}{
+		{"not an array", `{"a":1}`, ""},
+		{"key not found", `{"a":[1]}`, "b"},
+		{"not an object", `[1]`, "a"},
+		{"path not an array", `{"a":{"b":1}}`, "a.b"},
+		{"index out of range", `{"a":[[1]]}`, "a[1]"},
+		{"index on an object", `{"a":{}}`, "a[0]"},
+		{"bad path", `[]`, "a["},
+		{"truncated", `[{"a":1},{"a":`, ""},
+		{"malformed", `[{"a":1}}`, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := NewArrayReader(strings.NewReader(test.data), test.path)
+			if _, err := readAll(reader); err == nil {
+				t.Fatal("no error")
+			}
+			if _, err := reader.NextValue(); err != io.EOF {
+				t.Errorf("NextValue after an error = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestArrayReaderNext(t *testing.T) {
+	reader := NewArrayReader(strings.NewReader(`[{"id":9007199254740993},2]`), "").UseNumber()
+	obj, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := obj.GetInt64E("id"); err != nil || id != 9007199254740993 {
+		t.Errorf("id = %d, %v, want 9007199254740993 with UseNumber", id, err)
+	}
+	if _, err := reader.Next(); err == nil || !strings.Contains(err.Error(), "element 1 is not an object") {
+		t.Errorf("Next of a number = %v, want an error", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := reader.Next(); err != io.EOF {
+			t.Errorf("Next after the last element = %v, want io.EOF", err)
+		}
+	}
+}
+
+func TestArrayReaderEach(t *testing.T) {
+	data := `[{"n":0},{"n":1},{"n":2}]`
+	var seen []int
+	err := NewArrayReader(strings.NewReader(data), "").Each(func(idx int, obj *JSONObject) error {
+		if obj.GetInt("n") != idx {
+			t.Errorf("element %d holds %d", idx, obj.GetInt("n"))
+		}
+		seen = append(seen, idx)
+		return nil
+	})
+	if err != nil || len(seen) != 3 {
+		t.Errorf("Each = %v, saw %v, want 3 elements", err, seen)
+	}
+	errStop := errors.New("stop")
+	seen = nil
+	err = NewArrayReader(strings.NewReader(data), "").Each(func(idx int, obj *JSONObject) error {
+		seen = append(seen, idx)
+		if idx == 1 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop || len(seen) != 2 {
+		t.Errorf("Each = %v, saw %v, want errStop after 2 elements", err, seen)
+	}
+}
+
+type failAfterReader struct {
+	data []byte
+}
+
+var errStreamBroken = errors.New("stream broken")
+
+func (reader *failAfterReader) Read(p []byte) (int, error) {
+	if len(reader.data) == 0 {
+		return 0, errStreamBroken
+	}
+	n := copy(p, reader.data)
+	reader.data = reader.data[n:]
+	return n, nil
+}
+
+// TestArrayReaderStreams checks that an element is returned before the rest of the input
+// is read, so large arrays don't have to fit in memory.
+func TestArrayReaderStreams(t *testing.T) {
+	reader := NewArrayReader(&failAfterReader{[]byte(`[{"a":1},{"a":2},`)}, "")
+	for i := 1; i <= 2; i++ {
+		obj, err := reader.Next()
+		if err != nil {
+			t.Fatalf("element %d: %v", i, err)
+		}
+		if obj.GetInt("a") != i {
+			t.Errorf("element %d = %s", i, obj.ToJSONString())
+		}
+	}
+	if _, err := reader.Next(); err != errStreamBroken {
+		t.Errorf("Next past the input = %v, want errStreamBroken", err)
+	}
+}
+
+func TestArrayWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   string
+	}{
+		{"empty", nil, `[]`},
+		{"values", []interface{}{1, "x", nil, map[string]interface{}{"a": []int{1}}}, `[1,"x",null,{"a":[1]}]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			arrayWriter := NewArrayWriter(&buf)
+			for _, value := range test.values {
+				if err := arrayWriter.Write(value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := arrayWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := arrayWriter.Close(); err != nil {
+				t.Errorf("second Close = %v", err)
+			}
+			if buf.String() != test.want {
+				t.Errorf("output = %s, want %s", buf.String(), test.want)
+			}
+			if err := arrayWriter.Write(1); err == nil {
+				t.Error("Write after Close returned no error")
+			}
+		})
+	}
+}
+
+func TestArrayWriterReaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	arrayWriter := NewArrayWriter(&buf)
+	for i := 0; i < 1000; i++ {
+		obj := NewObject()
+		obj.Put("i", i)
+		if err := arrayWriter.Write(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := arrayWriter.Write(func() {}); err == nil {
+		t.Error("Write of a func returned no error")
+	}
+	if err := arrayWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	err := NewArrayReader(&buf, "").Each(func(idx int, obj *JSONObject) error {
+		if obj.GetInt("i") != idx {
+			t.Fatalf("element %d = %s", idx, obj.ToJSONString())
+		}
+		count++
+		return nil
+	})
+	if err != nil || count != 1000 {
+		t.Errorf("read %d elements, %v, want 1000", count, err)
+	}
+}